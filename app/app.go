@@ -3,9 +3,17 @@ package app
 import (
 	"os"
 
+	"github.com/alexej-v/grpc_cli/batch"
+	"github.com/alexej-v/grpc_cli/bench"
 	"github.com/alexej-v/grpc_cli/cli"
 	"github.com/alexej-v/grpc_cli/config"
+	"github.com/alexej-v/grpc_cli/gateway"
+	"github.com/alexej-v/grpc_cli/health"
+	"github.com/alexej-v/grpc_cli/mock"
 	"github.com/alexej-v/grpc_cli/proto"
+	"github.com/alexej-v/grpc_cli/proxy"
+
+	"github.com/pkg/errors"
 )
 
 type app struct {
@@ -19,6 +27,10 @@ func (a *app) initConfig() (err error) {
 }
 
 func (a *app) initSpec() (err error) {
+	if len(a.cfg.Default.Protoset) > 0 {
+		a.spec, err = proto.ParseProtoset(a.cfg.Default.Protoset)
+		return err
+	}
 	a.spec, err = proto.Parse(a.cfg.Default.ProtoFile, a.cfg.Default.ProtoPath)
 	return err
 }
@@ -39,5 +51,30 @@ func Run() (err error) {
 		return nil
 	}
 
-	return cli.Run(cli.DefaultConfig(newApp.cfg, newApp.spec))
+	switch newApp.cfg.Command {
+	case "":
+		return cli.Run(cli.DefaultConfig(newApp.cfg, newApp.spec))
+	case config.CommandServe:
+		return mock.Serve(newApp.cfg, newApp.spec)
+	case config.CommandBench:
+		return bench.Run(newApp.cfg, newApp.spec)
+	case config.CommandBatch:
+		return batch.Run(newApp.cfg, newApp.spec)
+	case config.CommandHealth:
+		return health.Run(newApp.cfg)
+	case config.CommandGateway:
+		return gateway.Serve(newApp.cfg, newApp.spec)
+	case config.CommandProxy:
+		return proxy.Serve(newApp.cfg, newApp.spec)
+	default:
+		return errors.Errorf("unknown command \"%s\"", newApp.cfg.Command)
+	}
+}
+
+// ExitCode returns the process exit code for the error returned by Run.
+func ExitCode(err error) int {
+	if coder, ok := errors.Cause(err).(interface{ ExitCode() int }); ok {
+		return coder.ExitCode()
+	}
+	return 1
 }
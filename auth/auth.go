@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/config"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// refreshBefore is how long before expiry a token is refreshed.
+	refreshBefore = time.Minute
+	defaultType   = "Bearer"
+)
+
+// Token is an access token with an optional expiry.
+type Token struct {
+	Value string
+	// Type is the authorization scheme, "Bearer" if empty.
+	Type string
+	// Expiry is zero if the token does not expire or it is unknown.
+	Expiry time.Time
+}
+
+func (t *Token) header() string {
+	tokenType := t.Type
+	if tokenType == "" {
+		tokenType = defaultType
+	}
+	return tokenType + " " + t.Value
+}
+
+// Provider obtains tokens.
+type Provider interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// Credentials implements credentials.PerRPCCredentials, it caches tokens of
+// the provider and refreshes them before expiry.
+type Credentials struct {
+	provider Provider
+
+	mu    sync.Mutex
+	token *Token
+}
+
+var _ credentials.PerRPCCredentials = (*Credentials)(nil)
+
+// NewCredentials wraps the provider.
+func NewCredentials(provider Provider) *Credentials {
+	return &Credentials{provider: provider}
+}
+
+// Token returns a valid token, refreshing it if needed.
+func (c *Credentials) Token(ctx context.Context) (*Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != nil && !c.token.Expiry.IsZero() && time.Now().Add(refreshBefore).Before(c.token.Expiry) {
+		return c.token, nil
+	}
+	token, err := c.provider.Token(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "auth: failed to get token")
+	}
+	c.token = token
+	return token, nil
+}
+
+// GetRequestMetadata returns the authorization header.
+func (c *Credentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	token, err := c.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": token.header()}, nil
+}
+
+// RequireTransportSecurity returns false, tokens are sent over plaintext
+// connections as well, like headers set in the shell.
+func (c *Credentials) RequireTransportSecurity() bool {
+	return false
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]*Credentials)
+)
+
+// FromConfig returns credentials of the configured provider or nil if none
+// is configured. Equal configurations share credentials, so cached tokens
+// survive reconnects.
+func FromConfig(cfg *config.Auth) *Credentials {
+	var provider Provider
+	switch {
+	case cfg.TokenFile != "":
+		provider = &FileProvider{Path: cfg.TokenFile}
+	case cfg.TokenURL != "":
+		provider = &ClientCredentialsProvider{
+			TokenURL:     cfg.TokenURL,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Scopes:       cfg.Scopes,
+		}
+	case cfg.Exec != "":
+		provider = &ExecProvider{Command: cfg.Exec}
+	default:
+		return nil
+	}
+
+	key := fmt.Sprintf("%#v", *cfg)
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if creds, ok := cache[key]; ok {
+		return creds
+	}
+	creds := NewCredentials(provider)
+	cache[key] = creds
+	return creds
+}
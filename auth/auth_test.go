@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientCredentialsProvider(t *testing.T) {
+	var issued int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "client_credentials" ||
+			id != "cli" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": "invalid_client"}`)
+			return
+		}
+		if got := r.Form.Get("scope"); got != "read write" {
+			t.Errorf("got scope %q", got)
+		}
+		n := atomic.AddInt64(&issued, 1)
+		// The token expires within refreshBefore, so every call refreshes it.
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": 30}`, n)
+	}))
+	defer srv.Close()
+
+	creds := NewCredentials(&ClientCredentialsProvider{
+		TokenURL: srv.URL, ClientID: "cli", ClientSecret: "s3cret", Scopes: []string{"read", "write"},
+	})
+	for i := 1; i <= 2; i++ {
+		md, err := creds.GetRequestMetadata(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("Bearer token-%d", i); md["authorization"] != want {
+			t.Errorf("got %q, want %q", md["authorization"], want)
+		}
+	}
+
+	bad := NewCredentials(&ClientCredentialsProvider{TokenURL: srv.URL, ClientID: "cli", ClientSecret: "wrong"})
+	if _, err := bad.GetRequestMetadata(context.Background()); err == nil {
+		t.Error("expected an error for invalid client")
+	}
+}
+
+func TestTokenWithoutExpiry(t *testing.T) {
+	var issued int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&issued, 1)
+		fmt.Fprint(w, `{"access_token": "token", "token_type": "bearer"}`)
+	}))
+	defer srv.Close()
+
+	creds := NewCredentials(&ClientCredentialsProvider{TokenURL: srv.URL})
+	for i := 0; i < 3; i++ {
+		if _, err := creds.GetRequestMetadata(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if issued != 1 {
+		t.Errorf("token endpoint called %d times, want 1", issued)
+	}
+
+	token, err := (&ExecProvider{Command: `echo '{"token": "json-token"}'`}).Token(context.Background())
+	if err != nil || token.Expiry.IsZero() {
+		t.Errorf("got %+v, %v, want a token cached for a while", token, err)
+	}
+}
+
+func TestCredentialsCache(t *testing.T) {
+	var calls int64
+	creds := NewCredentials(providerFunc(func(context.Context) (*Token, error) {
+		atomic.AddInt64(&calls, 1)
+		return &Token{Value: "t", Expiry: time.Now().Add(time.Hour)}, nil
+	}))
+	for i := 0; i < 3; i++ {
+		if _, err := creds.GetRequestMetadata(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("provider called %d times, want 1", calls)
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	if err = ioutil.WriteFile(path, []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &FileProvider{Path: path}
+	token, err := p.Token(context.Background())
+	if err != nil || token.Value != "first" {
+		t.Fatalf("got %v, %v", token, err)
+	}
+	if err = ioutil.WriteFile(path, []byte("second"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err = os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if token, err = p.Token(context.Background()); err != nil || token.Value != "second" {
+		t.Fatalf("got %v, %v", token, err)
+	}
+}
+
+func TestExecProvider(t *testing.T) {
+	token, err := (&ExecProvider{Command: "echo plain-token"}).Token(context.Background())
+	if err != nil || token.Value != "plain-token" || token.Expiry.IsZero() {
+		t.Fatalf("got %+v, %v", token, err)
+	}
+
+	token, err = (&ExecProvider{
+		Command: `echo '{"token": "json-token", "expiry": "2030-01-02T15:04:05Z"}'`,
+	}).Token(context.Background())
+	if err != nil || token.Value != "json-token" || token.Expiry.Year() != 2030 {
+		t.Fatalf("got %+v, %v", token, err)
+	}
+
+	if _, err = (&ExecProvider{Command: "exit 1"}).Token(context.Background()); err == nil {
+		t.Error("expected an error for a failing command")
+	}
+}
+
+type providerFunc func(context.Context) (*Token, error)
+
+func (f providerFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// tokenTTL is the lifetime of issued tokens without a known expiry, they
+// are cached and fetched again after it.
+const tokenTTL = 5 * time.Minute
+
+// FileProvider reads a static token from a file, the file is read again
+// once it is modified.
+type FileProvider struct {
+	Path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	token   *Token
+}
+
+func (p *FileProvider) Token(context.Context) (*Token, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	if p.token != nil && info.ModTime().Equal(p.modTime) {
+		return p.token, nil
+	}
+	b, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	value := strings.TrimSpace(string(b))
+	if value == "" {
+		return nil, errors.Errorf("token file %s is empty", p.Path)
+	}
+	p.token, p.modTime = &Token{Value: value}, info.ModTime()
+	return p.token, nil
+}
+
+// ClientCredentialsProvider obtains tokens with the OAuth2 client
+// credentials grant.
+type ClientCredentialsProvider struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Client is used for token requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// tokenResponse is a successful or an error response of a token endpoint.
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Expiry           string `json:"expiry"`
+	Token            string `json:"token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (r *tokenResponse) token(now time.Time) (*Token, error) {
+	t := &Token{Value: r.AccessToken, Type: r.TokenType}
+	if t.Value == "" {
+		t.Value = r.Token
+	}
+	if t.Value == "" {
+		return nil, errors.New("response holds no token")
+	}
+	// Token types are case insensitive, "bearer" is common in responses.
+	if strings.EqualFold(t.Type, defaultType) {
+		t.Type = defaultType
+	}
+	switch {
+	case r.ExpiresIn > 0:
+		t.Expiry = now.Add(time.Duration(r.ExpiresIn) * time.Second)
+	case r.Expiry != "":
+		expiry, err := time.Parse(time.RFC3339, r.Expiry)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid expiry")
+		}
+		t.Expiry = expiry
+	default:
+		t.Expiry = now.Add(tokenTTL)
+	}
+	return t, nil
+}
+
+func (p *ClientCredentialsProvider) Token(ctx context.Context) (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(p.Scopes) > 0 {
+		form.Set("scope", strings.Join(p.Scopes, " "))
+	}
+	req, err := http.NewRequest(http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	cli := p.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	now := time.Now()
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "token request failed")
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read token response")
+	}
+
+	var tr tokenResponse
+	if err = json.Unmarshal(body, &tr); err != nil {
+		return nil, errors.Errorf("token endpoint returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	if tr.Error != "" {
+		return nil, errors.Errorf("token endpoint returned %s: %s %s", resp.Status, tr.Error, tr.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("token endpoint returned %s", resp.Status)
+	}
+	return tr.token(now)
+}
+
+// ExecProvider runs a shell command whose stdout is a token. The output is
+// either the token itself or a JSON object like
+// {"access_token": "...", "expires_in": 3600} or {"token": "...", "expiry": "<RFC 3339>"}.
+// Tokens without an expiry are cached for tokenTTL.
+type ExecProvider struct {
+	Command string
+}
+
+func (p *ExecProvider) Token(ctx context.Context) (*Token, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", p.Command)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	now := time.Now()
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "token command failed: %s", bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	out := bytes.TrimSpace(stdout.Bytes())
+	if len(out) == 0 {
+		return nil, errors.New("token command printed nothing")
+	}
+	if out[0] == '{' {
+		var tr tokenResponse
+		if err := json.Unmarshal(out, &tr); err != nil {
+			return nil, errors.Wrap(err, "failed to parse token command output")
+		}
+		return tr.token(now)
+	}
+	return &Token{Value: string(out), Expiry: now.Add(tokenTTL)}, nil
+}
@@ -0,0 +1,121 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/alexej-v/grpc_cli/jsonfields"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+)
+
+// Input formats of the batch command.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+const maxLineSize = 16 << 20
+
+// Request is a JSON request body read from a line of the input.
+type Request struct {
+	Line int
+	Body json.RawMessage
+}
+
+// ReadNDJSON reads a JSON request body per line, blank lines are skipped.
+func ReadNDJSON(r io.Reader) ([]Request, error) {
+	var reqs []Request
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxLineSize)
+	for line := 1; sc.Scan(); line++ {
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		if !json.Valid(b) {
+			return nil, errors.Errorf("line %d is not valid JSON", line)
+		}
+		reqs = append(reqs, Request{Line: line, Body: append(json.RawMessage(nil), b...)})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read input")
+	}
+	return reqs, nil
+}
+
+// ParseColumns parses "column=path" mappings of CSV columns to field paths.
+func ParseColumns(mappings []string) (map[string]string, error) {
+	columns := make(map[string]string, len(mappings))
+	for _, m := range mappings {
+		i := strings.Index(m, "=")
+		if i <= 0 || i == len(m)-1 {
+			return nil, errors.Errorf("invalid column mapping %q, want column=path", m)
+		}
+		columns[strings.TrimSpace(m[:i])] = strings.TrimSpace(m[i+1:])
+	}
+	return columns, nil
+}
+
+// ReadCSV reads a request per row of the CSV with a header. Columns set the
+// fields of their paths in columns, or of their names if columns is empty;
+// other columns and empty cells are skipped. Lines of requests are rows
+// counting the header.
+func ReadCSV(r io.Reader, md *desc.MessageDescriptor, columns map[string]string) ([]Request, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read CSV header")
+	}
+	paths := make([]string, len(header))
+	found := make(map[string]bool)
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if len(columns) == 0 {
+			paths[i] = name
+			continue
+		}
+		if path, ok := columns[name]; ok {
+			paths[i] = path
+			found[name] = true
+		}
+	}
+	for name := range columns {
+		if !found[name] {
+			return nil, errors.Errorf("no CSV column %s", name)
+		}
+	}
+
+	var reqs []Request
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to read CSV")
+		}
+		obj := jsonfields.Object{}
+		for i, value := range record {
+			if paths[i] == "" || value == "" {
+				continue
+			}
+			if err = obj.SetJSON(md, paths[i], value); err != nil {
+				return nil, errors.Wrapf(err, "row %d, column %s", line, header[i])
+			}
+		}
+		body, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, Request{Line: line, Body: body})
+	}
+	return reqs, nil
+}
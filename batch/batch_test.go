@@ -0,0 +1,107 @@
+package batch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+const notifyProto = `syntax = "proto3";
+package test.batch;
+
+enum Channel { UNKNOWN = 0; EMAIL = 1; SMS = 2; }
+
+message Target { string user_id = 1; Channel channel = 2; }
+
+message ResendRequest {
+  int32 notification_id = 1;
+  bool urgent = 2;
+  Target target = 3;
+  repeated string tags = 4;
+}
+`
+
+func resendRequest(t *testing.T) *desc.MessageDescriptor {
+	dir, err := ioutil.TempDir("", "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "notify.proto"), []byte(notifyProto), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fds, err := protoparse.Parser{ImportPaths: []string{dir}}.ParseFiles("notify.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fds[0].FindMessage("test.batch.ResendRequest")
+}
+
+func TestReadNDJSON(t *testing.T) {
+	reqs, err := ReadNDJSON(strings.NewReader("{\"notificationId\": 1}\n\n  {\"notification_id\": 2}  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 2 || reqs[0].Line != 1 || reqs[1].Line != 3 || string(reqs[1].Body) != `{"notification_id": 2}` {
+		t.Errorf("unexpected requests %+v", reqs)
+	}
+	if _, err = ReadNDJSON(strings.NewReader("{}\n{\"a\": \n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got error %v, want one of line 2", err)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	md := resendRequest(t)
+	input := "id,urgent,user,channel,tags,comment\n" +
+		"1,true,u1,EMAIL,a,x\n" +
+		"2,,u2,2,\"[\"\"a\"\",\"\"b\"\"]\",y\n"
+	columns, err := ParseColumns([]string{
+		"id=notification_id", "urgent=urgent", "user=target.user_id", "channel=target.channel", "tags=tags",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs, err := ReadCSV(strings.NewReader(input), md, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`notification_id:1 urgent:true target:<user_id:"u1" channel:EMAIL> tags:"a"`,
+		`notification_id:2 target:<user_id:"u2" channel:SMS> tags:"a" tags:"b"`,
+	}
+	if len(reqs) != len(want) {
+		t.Fatalf("got %d requests, want %d", len(reqs), len(want))
+	}
+	for i, req := range reqs {
+		if req.Line != i+2 {
+			t.Errorf("request %d: got line %d, want %d", i, req.Line, i+2)
+		}
+		msg := dynamic.NewMessage(md)
+		if err = json.Unmarshal(req.Body, msg); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if got := msg.String(); got != want[i] {
+			t.Errorf("request %d: got %s, want %s", i, got, want[i])
+		}
+	}
+
+	// Columns are field paths without mappings.
+	reqs, err = ReadCSV(strings.NewReader("notificationId,target.user_id\n3,u3\n"), md, nil)
+	if err != nil || len(reqs) != 1 || string(reqs[0].Body) != `{"notification_id":3,"target":{"user_id":"u3"}}` {
+		t.Errorf("unexpected requests %+v, error %v", reqs, err)
+	}
+
+	if _, err = ReadCSV(strings.NewReader("id\n1\n"), md, map[string]string{"nope": "urgent"}); err == nil {
+		t.Error("expected an error of a missing column")
+	}
+	if _, err = ReadCSV(strings.NewReader("id\n1\n"), md, nil); err == nil || !strings.Contains(err.Error(), "row 2") {
+		t.Errorf("got error %v, want one of an unknown field in row 2", err)
+	}
+}
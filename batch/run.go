@@ -0,0 +1,197 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/bench"
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/config"
+	"github.com/alexej-v/grpc_cli/proto"
+
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxFailures limits failures listed in the summary, all of them are in
+// the results.
+const maxFailures = 20
+
+// Result is the outcome of a request written to the output as a JSON line.
+type Result struct {
+	Line     int             `json:"line"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Status   string          `json:"status"`
+	Error    string          `json:"error,omitempty"`
+	Latency  bench.Millis    `json:"latency_ms"`
+}
+
+// Run sends every request of the input file to the configured method,
+// writes the results and reports failures.
+func Run(cfg *config.Config, spec proto.Spec) error {
+	rpc, err := proto.FindRPC(spec, cfg.Default.Package, cfg.Default.Service, cfg.Default.Method)
+	if err != nil {
+		return errors.Wrap(err, "batch: failed to get RPC")
+	}
+	if rpc.IsClientStreaming || rpc.IsServerStreaming {
+		return errors.Errorf("batch: streaming method %s is not supported", rpc.FullyQualifiedName)
+	}
+	if len(cfg.Args) != 1 {
+		return errors.New("batch: usage: grpc_cli batch [flags] <requests.csv|requests.ndjson>")
+	}
+	reqs, err := read(cfg, rpc.RequestType.New)
+	if err != nil {
+		return errors.Wrap(err, "batch")
+	}
+	msgs := make([]interface{}, len(reqs))
+	for i, req := range reqs {
+		if msgs[i], err = rpc.RequestType.New(); err != nil {
+			return errors.Wrap(err, "batch: failed to create new RPC request")
+		}
+		if err = json.Unmarshal(req.Body, msgs[i]); err != nil {
+			return errors.Wrapf(err, "batch: failed to unmarshal line %d to RPC request", req.Line)
+		}
+	}
+	if len(reqs) == 0 {
+		fmt.Fprintln(os.Stderr, "no requests")
+		return nil
+	}
+
+	cli, err := client.NewClientFromConfig(cfg.Server)
+	if err != nil {
+		return errors.Wrap(err, "batch: failed to create new client")
+	}
+	defer cli.Close()
+
+	out := io.Writer(os.Stdout)
+	if cfg.Input.Output != "" {
+		f, err := os.Create(cfg.Input.Output)
+		if err != nil {
+			return errors.Wrap(err, "batch: failed to create output file")
+		}
+		defer f.Close()
+		out = f
+	}
+	w := &writer{enc: json.NewEncoder(out)}
+
+	opts := bench.Options{
+		Concurrency: cfg.Bench.Concurrency,
+		Requests:    len(reqs),
+		RPS:         cfg.Bench.RPS,
+	}
+	var next int64
+	report := bench.Do(context.Background(), opts, func(ctx context.Context) error {
+		i := atomic.AddInt64(&next, 1) - 1
+		resp, err := rpc.ResponseType.New()
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		err = cli.Invoke(ctx, rpc.FullyQualifiedName, msgs[i], resp)
+		res := Result{Line: reqs[i].Line, Status: status.Code(err).String(), Latency: bench.Millis(time.Since(start))}
+		res.Request, _ = json.Marshal(msgs[i])
+		if err != nil {
+			res.Error = status.Convert(err).Message()
+		} else {
+			res.Response, _ = json.Marshal(resp)
+		}
+		w.write(res)
+		return err
+	})
+	if w.err != nil {
+		return errors.Wrap(w.err, "batch: failed to write results")
+	}
+
+	// The summary goes to stderr, the results may be written to stdout.
+	if cfg.Input.Format == config.FormatJSON {
+		enc := json.NewEncoder(os.Stderr)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else if err = report.Print(os.Stderr, rpc.FullyQualifiedName); err == nil && len(w.failures) > 0 {
+		printFailures(os.Stderr, w.failures)
+	}
+	if err != nil || len(w.failures) == 0 {
+		return err
+	}
+	return errors.Errorf("batch: %d of %d requests failed", len(w.failures), len(reqs))
+}
+
+// read reads requests of the input file in the configured format.
+func read(cfg *config.Config, newRequest func() (interface{}, error)) ([]Request, error) {
+	name := cfg.Args[0]
+	format := cfg.Batch.Format
+	if format == "" {
+		format = FormatNDJSON
+		if strings.EqualFold(filepath.Ext(name), ".csv") {
+			format = FormatCSV
+		}
+	}
+	if format != FormatCSV && format != FormatNDJSON {
+		return nil, errors.Errorf("unknown input format %s", format)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open input file")
+	}
+	defer f.Close()
+	if format == FormatNDJSON {
+		return ReadNDJSON(f)
+	}
+
+	columns, err := ParseColumns(cfg.Batch.Columns)
+	if err != nil {
+		return nil, err
+	}
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	msg, ok := req.(*dynamic.Message)
+	if !ok {
+		return nil, errors.New("CSV input needs a dynamic request")
+	}
+	return ReadCSV(f, msg.GetMessageDescriptor(), columns)
+}
+
+// writer writes results as JSON lines and collects failures.
+type writer struct {
+	mu       sync.Mutex
+	enc      *json.Encoder
+	err      error
+	failures []Result
+}
+
+func (w *writer) write(res Result) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = w.enc.Encode(res)
+	}
+	if res.Status != codes.OK.String() {
+		w.failures = append(w.failures, res)
+	}
+}
+
+func printFailures(w io.Writer, failures []Result) {
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Line < failures[j].Line })
+	fmt.Fprintf(w, "\nFailures:\n")
+	for i, res := range failures {
+		if i == maxFailures {
+			fmt.Fprintf(w, "  ... and %d more\n", len(failures)-maxFailures)
+			break
+		}
+		fmt.Fprintf(w, "  line %d: [%s] %s\n", res.Line, res.Status, res.Error)
+	}
+}
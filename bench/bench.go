@@ -0,0 +1,220 @@
+package bench
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultRequests = 200
+	histogramBins   = 10
+	maxErrorSamples = 10
+)
+
+// Options control the load generated by Do.
+type Options struct {
+	// Concurrency is the number of workers sending requests.
+	Concurrency int
+	// Requests is the total number of requests, unlimited if Duration is set.
+	Requests int
+	// Duration stops the benchmark after the period.
+	Duration time.Duration
+	// RPS limits requests per second of all workers, unlimited if zero.
+	RPS int
+}
+
+// Report is a summary of a benchmark.
+type Report struct {
+	Count       int            `json:"count"`
+	Total       Millis         `json:"total_ms"`
+	RPS         float64        `json:"rps"`
+	Latency     Latency        `json:"latency"`
+	Histogram   []Bucket       `json:"histogram"`
+	StatusCodes map[string]int `json:"status_codes"`
+	// ErrorSamples counts distinct error messages, up to maxErrorSamples.
+	ErrorSamples map[string]int `json:"error_samples,omitempty"`
+}
+
+// Latency holds latency statistics.
+type Latency struct {
+	Min  Millis `json:"min_ms"`
+	Mean Millis `json:"mean_ms"`
+	Max  Millis `json:"max_ms"`
+	P50  Millis `json:"p50_ms"`
+	P75  Millis `json:"p75_ms"`
+	P90  Millis `json:"p90_ms"`
+	P95  Millis `json:"p95_ms"`
+	P99  Millis `json:"p99_ms"`
+}
+
+// Bucket counts requests with latency up to Mark.
+type Bucket struct {
+	Mark      Millis  `json:"mark_ms"`
+	Count     int     `json:"count"`
+	Frequency float64 `json:"frequency"`
+}
+
+// Millis is a duration encoded to JSON as fractional milliseconds.
+type Millis time.Duration
+
+func (m Millis) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatFloat(time.Duration(m).Seconds()*1000, 'f', 3, 64)), nil
+}
+
+func (m Millis) String() string {
+	return time.Duration(m).Round(10 * time.Microsecond).String()
+}
+
+type result struct {
+	latency time.Duration
+	err     error
+}
+
+// Do calls fn according to the options and reports the results. Errors of
+// fn are classified by their gRPC status codes.
+func Do(ctx context.Context, opts Options, fn func(ctx context.Context) error) *Report {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Requests <= 0 && opts.Duration <= 0 {
+		opts.Requests = defaultRequests
+	}
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	var tokens <-chan time.Time
+	if opts.RPS > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(opts.RPS))
+		defer ticker.Stop()
+		tokens = ticker.C
+	}
+
+	var (
+		sent    int64
+		mu      sync.Mutex
+		results []result
+		wg      sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var local []result
+			defer func() {
+				mu.Lock()
+				results = append(results, local...)
+				mu.Unlock()
+			}()
+			for {
+				if opts.Requests > 0 && atomic.AddInt64(&sent, 1) > int64(opts.Requests) {
+					return
+				}
+				if tokens != nil {
+					select {
+					case <-tokens:
+					case <-ctx.Done():
+						return
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				callStart := time.Now()
+				err := fn(ctx)
+				// Calls interrupted by the end of the benchmark are not counted.
+				if err != nil && ctx.Err() != nil {
+					return
+				}
+				local = append(local, result{latency: time.Since(callStart), err: err})
+			}
+		}()
+	}
+	wg.Wait()
+	return newReport(results, time.Since(start))
+}
+
+func newReport(results []result, total time.Duration) *Report {
+	r := &Report{
+		Count:       len(results),
+		Total:       Millis(total),
+		StatusCodes: make(map[string]int),
+	}
+	if len(results) == 0 {
+		return r
+	}
+	r.RPS = float64(len(results)) / total.Seconds()
+
+	latencies := make([]time.Duration, len(results))
+	var sum time.Duration
+	for i, res := range results {
+		latencies[i] = res.latency
+		sum += res.latency
+		r.StatusCodes[status.Code(res.err).String()]++
+		if res.err == nil {
+			continue
+		}
+		msg := res.err.Error()
+		if _, ok := r.ErrorSamples[msg]; ok || len(r.ErrorSamples) < maxErrorSamples {
+			if r.ErrorSamples == nil {
+				r.ErrorSamples = make(map[string]int)
+			}
+			r.ErrorSamples[msg]++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	r.Latency = Latency{
+		Min:  Millis(latencies[0]),
+		Mean: Millis(sum / time.Duration(len(latencies))),
+		Max:  Millis(latencies[len(latencies)-1]),
+		P50:  percentile(latencies, 50),
+		P75:  percentile(latencies, 75),
+		P90:  percentile(latencies, 90),
+		P95:  percentile(latencies, 95),
+		P99:  percentile(latencies, 99),
+	}
+	r.Histogram = histogram(latencies)
+	return r
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies.
+func percentile(sorted []time.Duration, p int) Millis {
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return Millis(sorted[i])
+}
+
+// histogram splits sorted latencies to buckets of equal width.
+func histogram(sorted []time.Duration) []Bucket {
+	min, max := sorted[0], sorted[len(sorted)-1]
+	width := (max - min) / histogramBins
+	buckets := make([]Bucket, histogramBins+1)
+	for i := range buckets {
+		buckets[i].Mark = Millis(min + width*time.Duration(i))
+	}
+	buckets[histogramBins].Mark = Millis(max)
+
+	b := 0
+	for _, l := range sorted {
+		for b < histogramBins && l > time.Duration(buckets[b].Mark) {
+			b++
+		}
+		buckets[b].Count++
+	}
+	for i := range buckets {
+		buckets[i].Frequency = float64(buckets[i].Count) / float64(len(sorted))
+	}
+	return buckets
+}
@@ -0,0 +1,60 @@
+package bench
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDoRequests(t *testing.T) {
+	var calls int64
+	report := Do(context.Background(), Options{Concurrency: 4, Requests: 100}, func(ctx context.Context) error {
+		if atomic.AddInt64(&calls, 1)%10 == 0 {
+			return status.Error(codes.Unavailable, "down")
+		}
+		return nil
+	})
+	if calls != 100 || report.Count != 100 {
+		t.Fatalf("got %d calls and count %d, want 100", calls, report.Count)
+	}
+	if report.StatusCodes["OK"] != 90 || report.StatusCodes["Unavailable"] != 10 {
+		t.Errorf("unexpected status codes %v", report.StatusCodes)
+	}
+	if report.ErrorSamples["rpc error: code = Unavailable desc = down"] != 10 {
+		t.Errorf("unexpected error samples %v", report.ErrorSamples)
+	}
+	count := 0
+	for _, b := range report.Histogram {
+		count += b.Count
+	}
+	if count != 100 {
+		t.Errorf("histogram holds %d requests, want 100", count)
+	}
+}
+
+func TestDoDurationRPS(t *testing.T) {
+	report := Do(context.Background(), Options{Concurrency: 2, Duration: 300 * time.Millisecond, RPS: 50},
+		func(ctx context.Context) error { return nil },
+	)
+	// 50 rps over 300ms allows about 15 requests.
+	if report.Count < 5 || report.Count > 20 {
+		t.Errorf("got %d requests, want about 15", report.Count)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+	if p := percentile(sorted, 50); time.Duration(p) != 50*time.Millisecond {
+		t.Errorf("p50 = %s, want 50ms", p)
+	}
+	if p := percentile(sorted, 99); time.Duration(p) != 99*time.Millisecond {
+		t.Errorf("p99 = %s, want 99ms", p)
+	}
+}
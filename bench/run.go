@@ -0,0 +1,131 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/config"
+	"github.com/alexej-v/grpc_cli/proto"
+
+	"github.com/pkg/errors"
+)
+
+// Run benchmarks the configured method and writes the report.
+func Run(cfg *config.Config, spec proto.Spec) error {
+	rpc, err := proto.FindRPC(spec, cfg.Default.Package, cfg.Default.Service, cfg.Default.Method)
+	if err != nil {
+		return errors.Wrap(err, "bench: failed to get RPC")
+	}
+	if rpc.IsClientStreaming || rpc.IsServerStreaming {
+		return errors.Errorf("bench: streaming method %s is not supported", rpc.FullyQualifiedName)
+	}
+	data, err := cfg.Input.Data()
+	if err != nil {
+		return err
+	}
+	req, err := rpc.RequestType.New()
+	if err != nil {
+		return errors.Wrap(err, "bench: failed to create new RPC request")
+	}
+	if data != "" {
+		if err = json.Unmarshal([]byte(data), req); err != nil {
+			return errors.Wrapf(err, "bench: failed to unmarshal data \"%s\" to RPC request", data)
+		}
+	}
+
+	cli, err := client.NewClientFromConfig(cfg.Server)
+	if err != nil {
+		return errors.Wrap(err, "bench: failed to create new client")
+	}
+	defer cli.Close()
+
+	opts := Options{
+		Concurrency: cfg.Bench.Concurrency,
+		Requests:    cfg.Bench.Requests,
+		Duration:    cfg.Bench.Duration,
+		RPS:         cfg.Bench.RPS,
+	}
+	report := Do(context.Background(), opts, func(ctx context.Context) error {
+		resp, err := rpc.ResponseType.New()
+		if err != nil {
+			return err
+		}
+		return cli.Invoke(ctx, rpc.FullyQualifiedName, req, resp)
+	})
+
+	out := io.Writer(os.Stdout)
+	if cfg.Input.Output != "" {
+		f, err := os.Create(cfg.Input.Output)
+		if err != nil {
+			return errors.Wrap(err, "bench: failed to create output file")
+		}
+		defer f.Close()
+		out = f
+	}
+	if cfg.Input.Format == config.FormatJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	return report.Print(out, rpc.FullyQualifiedName)
+}
+
+// Print writes the report in a human readable form.
+func (r *Report) Print(w io.Writer, method string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Summary:\n")
+	fmt.Fprintf(&b, "  Method:\t%s\n", method)
+	fmt.Fprintf(&b, "  Count:\t%d\n", r.Count)
+	fmt.Fprintf(&b, "  Total:\t%s\n", r.Total)
+	fmt.Fprintf(&b, "  Slowest:\t%s\n", r.Latency.Max)
+	fmt.Fprintf(&b, "  Fastest:\t%s\n", r.Latency.Min)
+	fmt.Fprintf(&b, "  Average:\t%s\n", r.Latency.Mean)
+	fmt.Fprintf(&b, "  Requests/sec:\t%.2f\n", r.RPS)
+
+	fmt.Fprintf(&b, "\nLatency distribution:\n")
+	for _, p := range []struct {
+		name string
+		val  Millis
+	}{
+		{"50", r.Latency.P50}, {"75", r.Latency.P75}, {"90", r.Latency.P90},
+		{"95", r.Latency.P95}, {"99", r.Latency.P99},
+	} {
+		fmt.Fprintf(&b, "  %s %% in %s\n", p.name, p.val)
+	}
+
+	if len(r.Histogram) > 0 {
+		fmt.Fprintf(&b, "\nResponse time histogram:\n")
+		for _, bucket := range r.Histogram {
+			bar := strings.Repeat("∎", int(bucket.Frequency*40+0.5))
+			fmt.Fprintf(&b, "  %-12s [%d]\t|%s\n", bucket.Mark, bucket.Count, bar)
+		}
+	}
+
+	fmt.Fprintf(&b, "\nStatus code distribution:\n")
+	for _, code := range sortedKeys(r.StatusCodes) {
+		fmt.Fprintf(&b, "  [%s]\t%d responses\n", code, r.StatusCodes[code])
+	}
+	if len(r.ErrorSamples) > 0 {
+		fmt.Fprintf(&b, "\nError distribution:\n")
+		for _, msg := range sortedKeys(r.ErrorSamples) {
+			fmt.Fprintf(&b, "  [%d]\t%s\n", r.ErrorSamples[msg], msg)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/config"
+	"github.com/alexej-v/grpc_cli/diff"
+	"github.com/alexej-v/grpc_cli/grpc"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const diffUsage = "usage: diff [--ignore path,...] <profile|host:port> <profile|host:port> <method> <json>"
+
+// profile lists profiles of the config file or switches to one of them.
+// Session headers are replaced with the headers of the profile, so secrets
+// of one target are not sent to another, unless --keep-headers is given.
+func (c *cliConfig) profile(cmd []string) {
+	fs := c.newFlagSet("profile")
+	keep := fs.Bool("keep-headers", false, "keep session headers, the profile headers override them")
+	if err := fs.Parse(cmd); err != nil {
+		c.Errorf(err.Error())
+		return
+	}
+	if cmd = fs.Args(); len(cmd) == 0 {
+		names := make([]string, 0, len(c.appCfg.Profiles))
+		for name := range c.appCfg.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		c.Infof(strings.Join(names, "\n"))
+		return
+	}
+	p, ok := c.appCfg.Profiles[cmd[0]]
+	if !ok {
+		c.Errorf("unknown profile \"%s\"", cmd[0])
+		return
+	}
+	srv := p.Server
+	c.appCfg.Server = &srv
+	if !*keep {
+		c.headers = client.Headers{}
+	}
+	for k, v := range p.Headers {
+		if err := c.headers.Set(k, v); err != nil {
+			c.Errorf("profile %s: %v", cmd[0], err)
+		}
+	}
+	c.showInfo()
+}
+
+// target resolves a profile name or a host:port address with the headers
+// sent to it: those of the profile, so secrets of the session are not sent
+// to other targets, or the session headers for an address.
+func (c *cliConfig) target(name string) (*config.Server, metadata.MD, error) {
+	if p, ok := c.appCfg.Profiles[name]; ok {
+		srv := p.Server
+		headers := client.Headers{}
+		for k, v := range p.Headers {
+			if err := headers.Set(k, v); err != nil {
+				return nil, nil, errors.Wrapf(err, "profile %s", name)
+			}
+		}
+		return &srv, headers.MD(), nil
+	}
+	host, port, err := net.SplitHostPort(name)
+	if err != nil {
+		return nil, nil, errors.Errorf("unknown profile \"%s\"", name)
+	}
+	// The address replaces the target of the session.
+	srv := *c.appCfg.Server
+	srv.Host, srv.Port = host, port
+	srv.Target, srv.Balancer = "", ""
+	return &srv, c.outgoingMetadata(), nil
+}
+
+// diff sends the same request to two targets and prints a field-level
+// diff of the responses.
+func (c *cliConfig) diff(cmd []string) {
+	fs := c.newFlagSet("diff")
+	ignore := fs.StringSlice("ignore", nil, "paths excluded from comparison, e.g. **.updated_at")
+	if err := fs.Parse(cmd); err != nil {
+		c.Errorf(err.Error())
+		return
+	}
+	args := fs.Args()
+	if len(args) < 4 {
+		c.Errorf(diffUsage)
+		return
+	}
+
+	rpc, err := c.spec.RPC(c.appCfg.Default.Package, c.appCfg.Default.Service, args[2])
+	if err != nil {
+		c.Errorf("failed to get RPC: %v", err)
+		return
+	}
+	if streaming(rpc) {
+		c.Errorf("diff compares responses of unary methods only")
+		return
+	}
+	req, err := c.newRequest(rpc, strings.Join(args[3:], lineDelimiter))
+	if err != nil {
+		c.Errorf(err.Error())
+		return
+	}
+
+	targets := args[:2]
+	results := make([]*callResult, len(targets))
+	var wg sync.WaitGroup
+	for i, name := range targets {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = c.callTarget(name, rpc, req, nil)
+		}(i, name)
+	}
+	wg.Wait()
+
+	bodies := make([][]byte, len(targets))
+	for i, res := range results {
+		if res.err != nil {
+			c.Errorf("%s: %v (%s)", targets[i], res.err, res.duration)
+			continue
+		}
+		c.Infof("%s: %s (%s)", targets[i], status.Code(res.err), res.duration)
+		if bodies[i], err = json.Marshal(res.resp); err != nil {
+			c.Errorf("failed to marshal RPC response: %v", err)
+			return
+		}
+	}
+
+	var changes []diff.Change
+	if codeA, codeB := status.Code(results[0].err), status.Code(results[1].err); codeA != codeB {
+		changes = append(changes, diff.Change{
+			Path: "status", Kind: diff.Changed, Old: codeA.String(), New: codeB.String(),
+		})
+	}
+	if results[0].err == nil && results[1].err == nil {
+		ignorePaths := append(append([]string(nil), c.appCfg.Diff.Ignore...), *ignore...)
+		respChanges, err := diff.JSON(bodies[0], bodies[1], ignorePaths...)
+		if err != nil {
+			c.Errorf(err.Error())
+			return
+		}
+		changes = append(changes, respChanges...)
+	}
+	if len(changes) == 0 {
+		c.Infof("responses are equal")
+		return
+	}
+	for _, change := range changes {
+		c.Errorf("%s", change)
+	}
+}
+
+// callTarget calls a profile or a host:port address with its headers and
+// the one-off "key:value" headers.
+func (c *cliConfig) callTarget(name string, rpc *grpc.RPC, req interface{}, oneOff []string) *callResult {
+	srv, headers, err := c.target(name)
+	if err != nil {
+		return &callResult{err: err}
+	}
+	meta, err := appendHeaders(headers, oneOff)
+	if err != nil {
+		return &callResult{err: err}
+	}
+	cli, err := client.NewClientFromConfig(srv)
+	if err != nil {
+		return &callResult{err: errors.Wrap(err, "failed to create new client")}
+	}
+	defer cli.Close()
+	return c.invoke(cli, rpc, req, meta)
+}
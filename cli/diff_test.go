@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/config"
+)
+
+func TestTarget(t *testing.T) {
+	c := &cliConfig{
+		appCfg: &config.Config{
+			Server: &config.Server{Target: "unix:///tmp/session.sock", Balancer: "round_robin", TLS: true},
+			Profiles: map[string]*config.Profile{
+				"staging": {Server: config.Server{Host: "staging.local", Port: "443"}, Headers: map[string]string{"x-env": "staging"}},
+			},
+		},
+		headers: client.Headers{"authorization": {"Bearer session"}},
+	}
+	srv, headers, err := c.target("localhost:50051")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Address(); got != "localhost:50051" || srv.Balancer != "" || !srv.TLS {
+		t.Errorf("got %s with balancer %q and TLS %v, want localhost:50051 with TLS of the session", got, srv.Balancer, srv.TLS)
+	}
+	if got := headers.Get("authorization"); len(got) != 1 || got[0] != "Bearer session" {
+		t.Errorf("address headers %v, want the session headers", headers)
+	}
+	if srv, headers, err = c.target("staging"); err != nil || srv.Address() != "staging.local:443" {
+		t.Errorf("unexpected profile target %+v, error %v", srv, err)
+	}
+	if len(headers) != 1 || len(headers.Get("x-env")) != 1 {
+		t.Errorf("profile headers %v, want only the headers of the profile", headers)
+	}
+	if _, _, err = c.target("nope"); err == nil {
+		t.Error("unknown profile accepted")
+	}
+}
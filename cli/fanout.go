@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/diff"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxGroupDepth limits groups nested in groups.
+const maxGroupDepth = 8
+
+// resolveTargets expands names of a fan-out call to profiles and
+// addresses: groups of the config file, patterns of profile names like
+// "shard-*", profile names and host:port addresses.
+func (c *cliConfig) resolveTargets(names []string) ([]string, error) {
+	var targets []string
+	seen := make(map[string]bool)
+	var resolve func(name string, depth int) error
+	resolve = func(name string, depth int) error {
+		if members, ok := c.appCfg.Groups[name]; ok {
+			if depth >= maxGroupDepth {
+				return errors.Errorf("group %s is nested too deeply", name)
+			}
+			for _, member := range members {
+				if err := resolve(member, depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		matched := []string{name}
+		// Addresses like [::1]:50051 are not patterns.
+		if _, _, err := net.SplitHostPort(name); err != nil && strings.ContainsAny(name, "*?[") {
+			matched = matched[:0]
+			for profile := range c.appCfg.Profiles {
+				if ok, err := path.Match(name, profile); err != nil {
+					return errors.Wrapf(err, "invalid target pattern %s", name)
+				} else if ok {
+					matched = append(matched, profile)
+				}
+			}
+			if len(matched) == 0 {
+				return errors.Errorf("no profile matches %s", name)
+			}
+			sort.Strings(matched)
+		}
+		for _, target := range matched {
+			if !seen[target] {
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+		return nil
+	}
+	for _, name := range names {
+		if err := resolve(strings.TrimSpace(name), 0); err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
+// fanOut sends the call to every target concurrently and prints the
+// status, latency and response of each one and a summary comparing them.
+func (c *cliConfig) fanOut(targets []string, cc *callCommand) {
+	results := make([]*callResult, len(targets))
+	var wg sync.WaitGroup
+	for i, name := range targets {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = c.callTarget(name, cc.rpc, cc.req, cc.oneOff)
+		}(i, name)
+	}
+	wg.Wait()
+
+	// Responses are compared by their printed parts.
+	outputs := make([]interface{}, len(targets))
+	for i, res := range results {
+		if c.appCfg.Verbose {
+			c.printVerbose(res)
+		}
+		if res.err != nil {
+			c.Errorf("%s: %v (%s)", targets[i], res.err, res.duration.Round(time.Microsecond))
+			continue
+		}
+		c.Infof("%s: %s in %s", targets[i], codes.OK, res.duration.Round(time.Microsecond))
+		if err := c.printResponse(res.resp, cc.out); err != nil {
+			c.Errorf("failed to print RPC response: %v", err)
+			continue
+		}
+		if v, err := decodeJSON(res.resp); err == nil {
+			if values, err := cc.out.apply(v); err == nil {
+				outputs[i] = values
+			}
+		}
+	}
+	c.printSummary(targets, results, outputs)
+}
+
+// printSummary shows counts of status codes, the latency range and groups
+// of targets with equal responses.
+func (c *cliConfig) printSummary(targets []string, results []*callResult, outputs []interface{}) {
+	counts := make(map[codes.Code]int)
+	var min, max, total time.Duration
+	var called int
+	for _, res := range results {
+		counts[status.Code(res.err)]++
+		// Targets failing before the call have no latency.
+		if res.duration == 0 {
+			continue
+		}
+		if called == 0 || res.duration < min {
+			min = res.duration
+		}
+		if res.duration > max {
+			max = res.duration
+		}
+		total += res.duration
+		called++
+	}
+	statuses := make([]codes.Code, 0, len(counts))
+	for code := range counts {
+		statuses = append(statuses, code)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+	parts := make([]string, len(statuses))
+	for i, code := range statuses {
+		parts[i] = fmt.Sprintf("%d %s", counts[code], code)
+	}
+	c.Infof("%d targets: %s", len(targets), strings.Join(parts, ", "))
+	if called > 0 {
+		c.Infof("latency: min %s, avg %s, max %s", min.Round(time.Microsecond),
+			(total / time.Duration(called)).Round(time.Microsecond), max.Round(time.Microsecond))
+	}
+
+	// Targets are grouped by equal responses, each group is compared to
+	// the first one.
+	var groups [][]int
+	keys := make(map[string]int)
+	for i, out := range outputs {
+		if out == nil {
+			continue
+		}
+		b, _ := json.Marshal(out)
+		g, ok := keys[string(b)]
+		if !ok {
+			g = len(groups)
+			keys[string(b)] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	switch len(groups) {
+	case 0:
+		return
+	case 1:
+		c.Infof("responses are equal")
+		return
+	}
+	c.Errorf("%d distinct responses:", len(groups))
+	first := groups[0][0]
+	for g, members := range groups {
+		names := make([]string, len(members))
+		for i, m := range members {
+			names[i] = targets[m]
+		}
+		c.Infof("  %d: %s", g+1, strings.Join(names, ", "))
+		if g == 0 {
+			continue
+		}
+		for _, change := range diff.Values(single(outputs[first]), single(outputs[members[0]])) {
+			c.Errorf("    %s", change)
+		}
+	}
+}
+
+// single returns the only printed value of a response.
+func single(out interface{}) interface{} {
+	if values, ok := out.([]interface{}); ok && len(values) == 1 {
+		return values[0]
+	}
+	return out
+}
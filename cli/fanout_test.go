@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alexej-v/grpc_cli/config"
+)
+
+func TestResolveTargets(t *testing.T) {
+	c := &cliConfig{appCfg: &config.Config{
+		Profiles: map[string]*config.Profile{"shard-1": {}, "shard-2": {}, "canary": {}},
+		Groups:   map[string][]string{"shards": {"shard-*"}, "all": {"shards", "canary", "[::1]:50051"}},
+	}}
+	targets, err := c.resolveTargets([]string{"all", "shard-1", "10.0.0.7:50051"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"shard-1", "shard-2", "canary", "[::1]:50051", "10.0.0.7:50051"}
+	if !reflect.DeepEqual(targets, want) {
+		t.Errorf("got %v, want %v", targets, want)
+	}
+	if _, err = c.resolveTargets([]string{"edge-*"}); err == nil {
+		t.Error("a pattern matching no profile accepted")
+	}
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/alexej-v/grpc_cli/cmdline"
+	"github.com/alexej-v/grpc_cli/query"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+const fieldMaskName = "google.protobuf.FieldMask"
+
+// output selects the printed part of responses.
+type output struct {
+	fields []string
+	query  *query.Query
+}
+
+func (o *output) empty() bool {
+	return len(o.fields) == 0 && o.query == nil
+}
+
+// printResponse prints the selected fields of the response, or every
+// result of the query.
+func (c *cliConfig) printResponse(resp interface{}, out *output) error {
+	if out == nil || out.empty() {
+		return c.PrintJSON(resp)
+	}
+	v, err := decodeJSON(resp)
+	if err != nil {
+		return err
+	}
+	values, err := out.apply(v)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		if err = c.PrintJSON(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply returns the value with the selected fields, or every result of
+// the query.
+func (o *output) apply(v interface{}) ([]interface{}, error) {
+	v = query.Select(v, o.fields)
+	if o.query == nil {
+		return []interface{}{v}, nil
+	}
+	return o.query.Run(v)
+}
+
+// decodeJSON converts the message to a value decoded from its JSON.
+func decodeJSON(msg interface{}) (interface{}, error) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err = json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// setFieldMask sets the first unset google.protobuf.FieldMask field of the
+// request to the paths.
+func setFieldMask(req interface{}, paths []string) error {
+	msg, ok := req.(*dynamic.Message)
+	if !ok {
+		return errors.New("field masks are set in dynamic requests only")
+	}
+	var mask *desc.FieldDescriptor
+	for _, fd := range msg.GetMessageDescriptor().GetFields() {
+		if fd.IsRepeated() || fd.GetMessageType() == nil || fd.GetMessageType().GetFullyQualifiedName() != fieldMaskName {
+			continue
+		}
+		if msg.HasField(fd) {
+			return nil
+		}
+		if mask == nil {
+			mask = fd
+		}
+	}
+	if mask == nil {
+		return errors.Errorf("request %s has no %s field", msg.GetMessageDescriptor().GetFullyQualifiedName(), fieldMaskName)
+	}
+	value := dynamic.NewMessage(mask.GetMessageType())
+	if err := value.TrySetFieldByName("paths", query.MaskPaths(paths)); err != nil {
+		return errors.Wrap(err, "failed to set field mask")
+	}
+	if err := msg.TrySetField(mask, value); err != nil {
+		return errors.Wrapf(err, "failed to set %s", mask.GetName())
+	}
+	return nil
+}
+
+// quotedFlags joins flag values quoted across the spaces the shell line is
+// split on, e.g. --query '.items[] | .sku', and strips the quotes. The
+// arguments after the first positional one are kept as they are.
+func quotedFlags(fs *pflag.FlagSet, args []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "--" {
+			return append(out, args[i:]...)
+		}
+		name := strings.TrimLeft(arg, "-")
+		if j := strings.Index(name, "="); j >= 0 {
+			value, n := cmdline.JoinQuoted(name[j+1:], args[i+1:])
+			out = append(out, arg[:len(arg)-len(name)+j+1]+value)
+			i += n
+			continue
+		}
+		out = append(out, arg)
+		var f *pflag.Flag
+		if strings.HasPrefix(arg, "--") {
+			f = fs.Lookup(name)
+		} else {
+			f = fs.ShorthandLookup(name)
+		}
+		if f == nil || f.NoOptDefVal != "" || i+1 == len(args) {
+			continue
+		}
+		value, n := cmdline.JoinQuoted(args[i+1], args[i+2:])
+		out = append(out, value)
+		i += n + 1
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/health"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const healthTimeout = 5 * time.Second
+
+// health checks the standard health service, with --watch it prints status
+// changes for the given period, or until interrupted if it is 0.
+func (c *cliConfig) health(cmd []string) {
+	fs := c.newFlagSet("health")
+	watch := fs.Duration("watch", 0, "watch status changes for the period, e.g. 30s, until interrupted with Ctrl-C if it is 0")
+	if err := fs.Parse(cmd); err != nil {
+		c.Errorf(err.Error())
+		return
+	}
+	var service string
+	if fs.NArg() > 0 {
+		service = fs.Arg(0)
+	}
+
+	cli, err := c.newClient()
+	if err != nil {
+		c.Errorf("failed to create new client: %v", err)
+		return
+	}
+	defer cli.Close()
+
+	if !fs.Changed("watch") {
+		ctx, cancel := context.WithTimeout(c.outgoingContext(), healthTimeout)
+		defer cancel()
+		st, err := health.Check(ctx, cli, service)
+		c.printHealth(service, st, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(c.outgoingContext())
+	defer cancel()
+	if *watch > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *watch)
+		defer cancel()
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	err = health.Watch(ctx, cli, service, func(st healthpb.HealthCheckResponse_ServingStatus) error {
+		c.printHealth(service, st, nil)
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		c.printHealth(service, healthpb.HealthCheckResponse_UNKNOWN, err)
+	}
+}
+
+func (c *cliConfig) printHealth(service string, st healthpb.HealthCheckResponse_ServingStatus, err error) {
+	if err = health.Result(service, st, err); err != nil {
+		c.Errorf(err.Error())
+		return
+	}
+	c.Infof(st.String())
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/grpc"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+// pageOptions limit the pages fetched by "call --all-pages", zero values
+// are unlimited.
+type pageOptions struct {
+	maxPages int
+	maxItems int
+	// stream prints the response of every page as it arrives instead of
+	// one response with the items of every page.
+	stream bool
+}
+
+// pager holds fields of AIP-158 list methods: page_token of the request,
+// next_page_token and the first repeated field of the response.
+type pager struct {
+	pageToken     *desc.FieldDescriptor
+	nextPageToken *desc.FieldDescriptor
+	items         *desc.FieldDescriptor
+}
+
+func newPager(rpc *grpc.RPC) (*pager, error) {
+	if rpc.IsServerStreaming || rpc.IsClientStreaming {
+		return nil, errors.Errorf("%s is a streaming method", rpc.Name)
+	}
+	req, err := messageDescriptor(rpc.RequestType)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := messageDescriptor(rpc.ResponseType)
+	if err != nil {
+		return nil, err
+	}
+	p := &pager{
+		pageToken:     stringField(req, "page_token"),
+		nextPageToken: stringField(resp, "next_page_token"),
+	}
+	for _, fd := range resp.GetFields() {
+		if fd.IsRepeated() && !fd.IsMap() {
+			p.items = fd
+			break
+		}
+	}
+	switch {
+	case p.pageToken == nil:
+		return nil, errors.Errorf("%s has no string page_token field", req.GetFullyQualifiedName())
+	case p.nextPageToken == nil:
+		return nil, errors.Errorf("%s has no string next_page_token field", resp.GetFullyQualifiedName())
+	case p.items == nil:
+		return nil, errors.Errorf("%s has no repeated field", resp.GetFullyQualifiedName())
+	}
+	return p, nil
+}
+
+func messageDescriptor(typ *grpc.Type) (*desc.MessageDescriptor, error) {
+	v, err := typ.New()
+	if err != nil {
+		return nil, err
+	}
+	msg, ok := v.(*dynamic.Message)
+	if !ok {
+		return nil, errors.Errorf("%s is not a dynamic message", typ.FullyQualifiedName)
+	}
+	return msg.GetMessageDescriptor(), nil
+}
+
+func stringField(md *desc.MessageDescriptor, name string) *desc.FieldDescriptor {
+	fd := md.FindFieldByName(name)
+	if fd == nil || fd.IsRepeated() || fd.GetType() != descriptor.FieldDescriptorProto_TYPE_STRING {
+		return nil
+	}
+	return fd
+}
+
+// callPages calls the list method with page tokens of responses until the
+// last page or a limit, items are printed with the output of the call.
+func (c *cliConfig) callPages(
+	cli client.Client, rpc *grpc.RPC, req interface{}, meta metadata.MD, opts pageOptions, out *output,
+) {
+	p, err := newPager(rpc)
+	if err != nil {
+		c.Errorf("failed to page %s: %v", rpc.Name, err)
+		return
+	}
+	msg := req.(*dynamic.Message)
+	var (
+		items []interface{}
+		last  *dynamic.Message
+		pages int
+		token string
+	)
+	for {
+		res := c.invoke(cli, rpc, msg, meta)
+		c.record(rpc, msg, meta, res)
+		if c.appCfg.Verbose {
+			c.printVerbose(res)
+		}
+		if res.err != nil {
+			c.Errorf("failed to request page %d: %v", pages+1, res.err)
+			break
+		}
+		pages++
+		last = res.resp.(*dynamic.Message)
+		page, _ := last.GetField(p.items).([]interface{})
+		if opts.maxItems > 0 && len(items)+len(page) > opts.maxItems {
+			page = page[:opts.maxItems-len(items)]
+		}
+		if c.appCfg.Verbose {
+			c.Infof("Page %d: %d items", pages, len(page))
+		}
+		items = append(items, page...)
+		if opts.stream {
+			// Pages are printed like the merged response, so --fields and
+			// --query select the same parts.
+			if err = last.TrySetField(p.items, page); err != nil {
+				c.Errorf("failed to limit page %d: %v", pages, err)
+				return
+			}
+			if err = c.printResponse(last, out); err != nil {
+				c.Errorf("failed to print RPC response: %v", err)
+				return
+			}
+		}
+
+		next, _ := last.GetField(p.nextPageToken).(string)
+		if next == "" {
+			break
+		}
+		if next == token {
+			c.Errorf("page %d repeats the page token %q", pages, next)
+			break
+		}
+		token = next
+		if opts.maxPages > 0 && pages >= opts.maxPages || opts.maxItems > 0 && len(items) >= opts.maxItems {
+			c.Infof("stopped after %d pages and %d items, next page token %q", pages, len(items), token)
+			break
+		}
+		if err = msg.TrySetField(p.pageToken, token); err != nil {
+			c.Errorf("failed to set page token: %v", err)
+			break
+		}
+	}
+	if opts.stream || last == nil {
+		return
+	}
+	// The last response carries the token of the next page, if any.
+	if err = last.TrySetField(p.items, items); err != nil {
+		c.Errorf("failed to merge pages: %v", err)
+		return
+	}
+	if err = c.printResponse(last, out); err != nil {
+		c.Errorf("failed to print RPC response: %v", err)
+	}
+}
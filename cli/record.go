@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/diff"
+	"github.com/alexej-v/grpc_cli/grpc"
+	"github.com/alexej-v/grpc_cli/record"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const recordOff = "off"
+
+// setRecord starts appending calls to the file, "off" stops recording.
+func (c *cliConfig) setRecord(path string) {
+	c.stopRecording()
+	if path == recordOff {
+		return
+	}
+	recorder, err := record.Open(path)
+	if err != nil {
+		c.Errorf(err.Error())
+		return
+	}
+	c.recorder = recorder
+}
+
+func (c *cliConfig) stopRecording() {
+	if c.recorder == nil {
+		return
+	}
+	if err := c.recorder.Close(); err != nil {
+		c.Errorf("failed to close record file: %v", err)
+	}
+	c.recorder = nil
+}
+
+func (c *cliConfig) record(rpc *grpc.RPC, req interface{}, meta metadata.MD, res *callResult) {
+	if c.recorder == nil {
+		return
+	}
+	rec, err := newRecord(c.appCfg.Server.Address(), rpc, req, meta, res)
+	if err == nil {
+		rec.Metadata = c.redactor.MD(rec.Metadata)
+		rec.Header = c.redactor.MD(rec.Header)
+		rec.Trailer = c.redactor.MD(rec.Trailer)
+		err = c.recorder.Write(rec)
+	}
+	if err != nil {
+		c.Errorf("failed to record call: %v", err)
+	}
+}
+
+func newRecord(target string, rpc *grpc.RPC, req interface{}, meta metadata.MD, res *callResult) (*record.Record, error) {
+	st := status.Convert(res.err)
+	rec := &record.Record{
+		Time:       time.Now().Add(-res.duration),
+		Target:     target,
+		Method:     rpc.FullyQualifiedName,
+		Metadata:   meta,
+		Status:     record.Status{Code: st.Code(), Message: st.Message()},
+		Header:     res.header,
+		Trailer:    res.trailer,
+		DurationMs: res.duration.Seconds() * 1000,
+	}
+	var err error
+	if reqs, ok := req.([]interface{}); ok {
+		rec.Request, err = messagesJSON(reqs)
+	} else {
+		rec.Request, err = json.Marshal(req)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal request")
+	}
+	// Streams are recorded with the responses received before a failure.
+	switch {
+	case streaming(rpc):
+		rec.Response, err = messagesJSON(res.responses)
+	case res.err == nil:
+		rec.Response, err = json.Marshal(res.resp)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal response")
+	}
+	return rec, nil
+}
+
+// replay re-issues recorded calls against the current target and prints
+// differences between recorded and new responses.
+func (c *cliConfig) replay(cmd []string) {
+	if len(cmd) < 1 {
+		return
+	}
+	recs, err := record.ReadFile(cmd[0])
+	if err != nil {
+		c.Errorf(err.Error())
+		return
+	}
+	cli, err := c.newClient()
+	if err != nil {
+		c.Errorf("failed to create new client: %v", err)
+		return
+	}
+	defer cli.Close()
+
+	differ := 0
+	for i, rec := range recs {
+		changes, res, err := c.replayRecord(cli, rec)
+		if err != nil {
+			differ++
+			c.Errorf("[%d/%d] %s: %v", i+1, len(recs), rec.Method, err)
+			continue
+		}
+		code := status.Code(res.err)
+		if len(changes) == 0 {
+			c.Infof("[%d/%d] %s: %s (%s), no differences", i+1, len(recs), rec.Method, code, res.duration)
+			continue
+		}
+		differ++
+		c.Errorf("[%d/%d] %s: %s (%s), %d differences", i+1, len(recs), rec.Method, code, res.duration, len(changes))
+		for _, change := range changes {
+			c.Errorf("  %s", change)
+		}
+	}
+	c.Infof("replayed %d calls, %d differ", len(recs), differ)
+}
+
+func (c *cliConfig) replayRecord(cli client.Client, rec *record.Record) ([]diff.Change, *callResult, error) {
+	rpc, err := c.spec.LookupRPC(rec.Method)
+	if err != nil {
+		return nil, nil, err
+	}
+	reqs, err := recordedRequests(rpc, rec.Request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Headers of the session take precedence, e.g. a fresh token, masked
+	// values of sensitive headers are never sent.
+	meta := metadata.MD{}
+	for k, v := range rec.Metadata {
+		if !c.redactor.Sensitive(k) {
+			meta[k] = v
+		}
+	}
+	for k, v := range c.outgoingMetadata() {
+		meta[k] = v
+	}
+	var res *callResult
+	if streaming(rpc) {
+		res = c.stream(cli, rpc, reqs, meta, func(interface{}) {})
+	} else {
+		res = c.invoke(cli, rpc, reqs[0], meta)
+	}
+
+	var changes []diff.Change
+	if code := status.Code(res.err); code != rec.Status.Code {
+		changes = append(changes, diff.Change{
+			Path: "status", Kind: diff.Changed, Old: rec.Status.Code.String(), New: code.String(),
+		})
+	}
+	if res.err != nil || len(rec.Response) == 0 {
+		return changes, res, nil
+	}
+	var b []byte
+	if streaming(rpc) {
+		b, err = messagesJSON(res.responses)
+	} else {
+		b, err = json.Marshal(res.resp)
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to marshal response")
+	}
+	respChanges, err := diff.JSON(rec.Response, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(changes, respChanges...), res, nil
+}
+
+// recordedRequests decodes the recorded request, or the JSON array of
+// requests of a client stream.
+func recordedRequests(rpc *grpc.RPC, data json.RawMessage) ([]interface{}, error) {
+	items := []json.RawMessage{data}
+	if rpc.IsClientStreaming {
+		switch trimmed := bytes.TrimSpace(data); {
+		case len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")):
+			items = nil
+		case trimmed[0] == '[':
+			if err := json.Unmarshal(trimmed, &items); err != nil {
+				return nil, errors.Wrap(err, "failed to unmarshal recorded requests")
+			}
+		}
+	}
+	reqs := make([]interface{}, len(items))
+	for i, item := range items {
+		req, err := rpc.RequestType.New()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create new RPC request")
+		}
+		if err = json.Unmarshal(item, req); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal recorded request")
+		}
+		reqs[i] = req
+	}
+	return reqs, nil
+}
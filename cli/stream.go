@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/grpc"
+
+	"github.com/pkg/errors"
+	grpcgo "google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// streaming reports whether the method streams requests or responses.
+func streaming(rpc *grpc.RPC) bool {
+	return rpc.IsClientStreaming || rpc.IsServerStreaming
+}
+
+// newRequests builds the requests of a call. Client streams take a JSON
+// array of requests or a single one.
+func (c *cliConfig) newRequests(rpc *grpc.RPC, data string) ([]interface{}, error) {
+	if !rpc.IsClientStreaming || !strings.HasPrefix(strings.TrimSpace(data), "[") {
+		req, err := c.newRequest(rpc, data)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{req}, nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(data), &items); err != nil {
+		return nil, errors.Wrap(err, "failed to parse requests of the stream")
+	}
+	reqs := make([]interface{}, len(items))
+	for i, item := range items {
+		req, err := c.newRequest(rpc, string(item))
+		if err != nil {
+			return nil, errors.Wrapf(err, "request %d", i+1)
+		}
+		reqs[i] = req
+	}
+	return reqs, nil
+}
+
+// stream sends the requests on a stream of the method, closes the sending
+// side and calls fn with every response as it arrives.
+func (c *cliConfig) stream(
+	cli client.Client, rpc *grpc.RPC, reqs []interface{}, meta metadata.MD, fn func(resp interface{}),
+) *callResult {
+	res := &callResult{stats: new(client.CallStats)}
+	ctx, cancel := context.WithCancel(client.WithStats(metadata.NewOutgoingContext(context.Background(), meta), res.stats))
+	defer cancel()
+	start := time.Now()
+	defer func() { res.duration = time.Since(start) }()
+
+	desc := &grpcgo.StreamDesc{
+		StreamName:    rpc.Name,
+		ServerStreams: rpc.IsServerStreaming,
+		ClientStreams: rpc.IsClientStreaming,
+	}
+	s, err := cli.NewStream(ctx, desc, rpc.FullyQualifiedName)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	for _, req := range reqs {
+		// io.EOF means the server ended the stream, its status is received.
+		if err = s.SendMsg(req); err != nil {
+			break
+		}
+	}
+	if err != nil && err != io.EOF {
+		res.err = err
+		return res
+	}
+	if err = s.CloseSend(); err != nil {
+		res.err = err
+		return res
+	}
+	for {
+		resp, err := rpc.ResponseType.New()
+		if err != nil {
+			res.err = errors.Wrap(err, "failed to create new RPC response")
+			return res
+		}
+		if err = s.RecvMsg(resp); err == io.EOF {
+			break
+		} else if err != nil {
+			res.err = err
+			break
+		}
+		res.responses = append(res.responses, resp)
+		fn(resp)
+		// A single response ends client streams.
+		if !rpc.IsServerStreaming {
+			break
+		}
+	}
+	res.header, _ = s.Header()
+	res.trailer = s.Trailer()
+	return res
+}
+
+// callStream prints responses of a streaming call as they arrive.
+func (c *cliConfig) callStream(cli client.Client, cc *callCommand) {
+	res := c.stream(cli, cc.rpc, cc.reqs, cc.meta, func(resp interface{}) {
+		if err := c.printResponse(resp, cc.out); err != nil {
+			c.Errorf("failed to print RPC response: %v", err)
+		}
+	})
+	c.record(cc.rpc, cc.reqs, cc.meta, res)
+	if c.appCfg.Verbose {
+		c.printVerbose(res)
+	}
+	switch n := res.attempts(); {
+	case res.err != nil && n > 1:
+		c.Errorf("failed to request RPC service after %d attempts: %v", n, res.err)
+	case res.err != nil:
+		c.Errorf("failed to request RPC service: %v", res.err)
+	case n > 1 && !c.appCfg.Verbose:
+		c.Infof("Attempts: %d", n)
+	}
+	if cc.rpc.IsServerStreaming && res.err == nil {
+		c.Infof("%d responses", len(res.responses))
+	}
+}
+
+// messagesJSON encodes messages of a stream like the proxy records them:
+// a single message as it is and several ones as a JSON array.
+func messagesJSON(msgs []interface{}) (json.RawMessage, error) {
+	switch len(msgs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return json.Marshal(msgs[0])
+	}
+	return json.Marshal(msgs)
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/auth"
+	"github.com/alexej-v/grpc_cli/jwt"
+)
+
+// expiryWarning is how long before expiry calls warn about the token.
+const expiryWarning = 5 * time.Minute
+
+// token decodes the JWT of the Authorization header, of the credential
+// provider or the given one, and verifies it if a JWKS file is set.
+func (c *cliConfig) token(cmd []string) {
+	fs := c.newFlagSet("token")
+	jwksPath := fs.String("jwks", c.appCfg.JWKS, "JWKS file verifying the signature")
+	if err := fs.Parse(cmd); err != nil {
+		c.Errorf(err.Error())
+		return
+	}
+
+	var raw, source string
+	if fs.NArg() > 0 {
+		raw, source = strings.Join(fs.Args(), lineDelimiter), "argument"
+	} else {
+		var err error
+		if raw, source, err = c.currentToken(); err != nil {
+			c.Errorf(err.Error())
+			return
+		}
+	}
+	if raw == "" {
+		c.Errorf("no token: set the Authorization header or a credential provider")
+		return
+	}
+	t, err := jwt.Parse(raw)
+	if err != nil {
+		c.Errorf(err.Error())
+		return
+	}
+
+	c.Infof("Source: %s", source)
+	c.Infof(tokenSummary(t, time.Now()))
+	if err = c.PrintJSON(t.Claims); err != nil {
+		c.Errorf("failed to marshal claims: %v", err)
+	}
+	if *jwksPath == "" {
+		return
+	}
+	jwks, err := jwt.LoadJWKS(*jwksPath)
+	if err == nil {
+		err = t.Verify(jwks)
+	}
+	if err != nil {
+		c.Errorf("Signature: %v", err)
+		return
+	}
+	c.Infof("Signature: valid (%s)", t.Algorithm())
+}
+
+// currentToken returns the token sent with calls and where it comes from.
+func (c *cliConfig) currentToken() (raw, source string, err error) {
+	if creds := auth.FromConfig(&c.appCfg.Server.Auth); creds != nil {
+		t, err := creds.Token(context.Background())
+		if err != nil {
+			return "", "", err
+		}
+		return t.Value, c.appCfg.Server.Auth.String(), nil
+	}
+	return c.headerToken(), "Authorization header", nil
+}
+
+// warnToken warns before a call if the JWT of the Authorization header is
+// expired or expires soon. Provider tokens are refreshed automatically.
+func (c *cliConfig) warnToken() {
+	raw := c.headerToken()
+	if raw == "" {
+		return
+	}
+	t, err := jwt.Parse(raw)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	exp, _ := t.Claims.ExpiresAt()
+	switch t.CheckExpiry(now, expiryWarning) {
+	case jwt.Expired:
+		c.Errorf("warning: the Authorization token expired %s ago", now.Sub(exp).Round(time.Second))
+	case jwt.ExpiresSoon:
+		c.Errorf("warning: the Authorization token expires in %s", exp.Sub(now).Round(time.Second))
+	}
+}
+
+func tokenSummary(t *jwt.Token, now time.Time) string {
+	lines := []string{
+		fmt.Sprintf("Subject: %s", t.Claims.Subject()),
+		fmt.Sprintf("Issuer: %s", t.Claims.Issuer()),
+	}
+	if iat, ok := t.Claims.IssuedAt(); ok {
+		lines = append(lines, fmt.Sprintf("Issued: %s", iat.Format(time.RFC3339)))
+	}
+	if exp, ok := t.Claims.ExpiresAt(); ok {
+		state := fmt.Sprintf("expires in %s", exp.Sub(now).Round(time.Second))
+		if !now.Before(exp) {
+			state = fmt.Sprintf("EXPIRED %s ago", now.Sub(exp).Round(time.Second))
+		}
+		lines = append(lines, fmt.Sprintf("Expires: %s (%s)", exp.Format(time.RFC3339), state))
+	}
+	if scopes := t.Claims.Scopes(); len(scopes) > 0 {
+		lines = append(lines, fmt.Sprintf("Scopes: %s", strings.Join(scopes, " ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tokenInfo is a one-line summary of the Authorization header token shown
+// by info.
+func (c *cliConfig) tokenInfo() string {
+	t, err := jwt.Parse(c.headerToken())
+	if err != nil {
+		return ""
+	}
+	info := fmt.Sprintf("sub %s, iss %s", t.Claims.Subject(), t.Claims.Issuer())
+	if exp, ok := t.Claims.ExpiresAt(); ok {
+		if now := time.Now(); now.Before(exp) {
+			info += fmt.Sprintf(", expires in %s", exp.Sub(now).Round(time.Second))
+		} else {
+			info += fmt.Sprintf(", EXPIRED %s ago", now.Sub(exp).Round(time.Second))
+		}
+	}
+	return info
+}
+
+func (c *cliConfig) headerToken() string {
+	if vals := c.headers["authorization"]; len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
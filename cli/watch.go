@@ -0,0 +1,277 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/diff"
+	"github.com/alexej-v/grpc_cli/query"
+
+	"google.golang.org/grpc/status"
+)
+
+const watchUsage = "usage: watch [-n 2s] [--until condition] [--count n] call [call flags] <method> <json>"
+
+// watch repeats a unary call on an interval, showing the latest response
+// and its changes since the previous call, until the condition holds, the
+// count is reached or it is interrupted with Ctrl-C.
+func (c *cliConfig) watch(cmd []string) {
+	fs := c.newFlagSet("watch")
+	interval := fs.DurationP("interval", "n", 2*time.Second, "interval between calls")
+	until := fs.String("until", "", `stop when the condition holds for the response, e.g. '.status == "DONE"'`)
+	count := fs.Int("count", 0, "max number of calls, unlimited by default")
+	if err := fs.Parse(quotedFlags(fs, cmd)); err != nil {
+		c.Errorf(err.Error())
+		return
+	}
+	args := fs.Args()
+	if len(args) < 3 || args[0] != "call" || *interval <= 0 {
+		c.Errorf(watchUsage)
+		return
+	}
+	var cond *query.Query
+	if *until != "" {
+		var err error
+		if cond, err = parseCondition(*until); err != nil {
+			c.Errorf(err.Error())
+			return
+		}
+	}
+	cc, err := c.parseCall(args[1:])
+	if err != nil {
+		c.Errorf(err.Error())
+		return
+	}
+	if cc == nil {
+		c.Errorf(watchUsage)
+		return
+	}
+	if cc.allPages || len(cc.targets) > 0 || streaming(cc.rpc) {
+		c.Errorf("watch calls unary methods of a single target without --all-pages and --targets")
+		return
+	}
+	cli, err := c.newClient()
+	if err != nil {
+		c.Errorf("failed to create new client: %v", err)
+		return
+	}
+	defer cli.Close()
+	c.warnToken()
+
+	// Ctrl-C cancels the call in flight too.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	var prev *watchState
+	for n := 1; ; n++ {
+		res := c.invokeContext(ctx, cli, cc.rpc, cc.req, cc.meta)
+		if ctx.Err() != nil {
+			return
+		}
+		c.record(cc.rpc, cc.req, cc.meta, res)
+		state := newWatchState(res, cc.out)
+
+		fmt.Fprint(c.rlI.Stdout(), "\033[H\033[2J")
+		c.Infof("Every %s: %s (call %d at %s, Ctrl-C to stop)",
+			*interval, cc.rpc.Name, n, time.Now().Format("15:04:05"))
+		if c.appCfg.Verbose {
+			c.printVerbose(res)
+		}
+		state.print(c, prev)
+		prev = state
+
+		if cond != nil && state.holds(cond) {
+			c.Infof("%s holds after %d calls", *until, n)
+			return
+		}
+		if *count > 0 && n >= *count {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// parseCondition parses a condition of select, e.g. `.status == "DONE"`,
+// or a whole query.
+func parseCondition(expr string) (*query.Query, error) {
+	if !strings.HasPrefix(strings.TrimSpace(expr), "select(") {
+		expr = "select(" + expr + ")"
+	}
+	return query.Parse(expr)
+}
+
+// watchState is the outcome of a watched call.
+type watchState struct {
+	err error
+	// resp is the decoded response, values are the printed parts of it.
+	resp   interface{}
+	values []interface{}
+}
+
+func newWatchState(res *callResult, out *output) *watchState {
+	s := &watchState{err: res.err}
+	if s.err != nil {
+		return s
+	}
+	if s.resp, s.err = decodeJSON(res.resp); s.err != nil {
+		return s
+	}
+	s.values, s.err = out.apply(s.resp)
+	return s
+}
+
+// print prints the response with fields added since the previous call in
+// green and changed ones in yellow, followed by removed fields in red and
+// a change of the status.
+func (s *watchState) print(c *cliConfig, prev *watchState) {
+	var changes []diff.Change
+	if prev != nil {
+		changes = prev.changes(s)
+	}
+	if s.err != nil {
+		c.Errorf("%v", s.err)
+	} else {
+		marks := make(map[string]diff.Kind)
+		if prev != nil && prev.err == nil {
+			for _, change := range changes {
+				marks[change.Path] = change.Kind
+			}
+		}
+		for i, v := range s.values {
+			// Paths of several values start with their index, see value.
+			var path string
+			if len(s.values) != 1 {
+				path = fmt.Sprintf("[%d]", i)
+			}
+			var b strings.Builder
+			markedJSON(&b, "", v, path, "", marks)
+			fmt.Fprintln(c.rlI.Stdout(), b.String())
+		}
+	}
+	if prev == nil {
+		return
+	}
+	if len(changes) == 0 {
+		c.Infof("no changes")
+	}
+	for _, change := range changes {
+		if change.Kind == diff.Removed || s.err != nil || prev.err != nil {
+			fmt.Fprintf(c.rlI.Stdout(), "%s%s\033[39m\n", changeColors[change.Kind], change)
+		}
+	}
+}
+
+// changeColors are terminal colors of changes between calls.
+var changeColors = map[diff.Kind]string{
+	diff.Added:   "\033[32m",
+	diff.Removed: "\033[31m",
+	diff.Changed: "\033[33m",
+}
+
+// markedJSON writes the value with its key indented like PrintJSON, values
+// at paths of the marks are colored with their keys.
+func markedJSON(b *strings.Builder, key string, v interface{}, path, indent string, marks map[string]diff.Kind) {
+	markPath := path
+	if markPath == "" {
+		markPath = "."
+	}
+	if kind, ok := marks[markPath]; ok {
+		raw, _ := json.MarshalIndent(v, indent, "  ")
+		fmt.Fprintf(b, "%s%s%s%s\033[39m", indent, changeColors[kind], key, raw)
+		return
+	}
+	b.WriteString(indent + key)
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			b.WriteString("{}")
+			return
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteString("{\n")
+		for i, k := range keys {
+			name, _ := json.Marshal(k)
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			markedJSON(b, string(name)+": ", val[k], child, indent+"  ", marks)
+			if i < len(keys)-1 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(indent + "}")
+	case []interface{}:
+		if len(val) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteString("[\n")
+		for i, elem := range val {
+			markedJSON(b, "", elem, fmt.Sprintf("%s[%d]", path, i), indent+"  ", marks)
+			if i < len(val)-1 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(indent + "]")
+	default:
+		raw, _ := json.Marshal(v)
+		b.Write(raw)
+	}
+}
+
+// value is what is compared between calls.
+func (s *watchState) value() interface{} {
+	if len(s.values) == 1 {
+		return s.values[0]
+	}
+	return s.values
+}
+
+func (s *watchState) changes(next *watchState) []diff.Change {
+	var changes []diff.Change
+	if a, b := status.Code(s.err), status.Code(next.err); a != b {
+		changes = append(changes, diff.Change{Path: "status", Kind: diff.Changed, Old: a.String(), New: b.String()})
+	}
+	if s.err == nil && next.err == nil {
+		changes = append(changes, diff.Values(s.value(), next.value())...)
+	}
+	return changes
+}
+
+// holds reports whether the condition selects the response.
+func (s *watchState) holds(cond *query.Query) bool {
+	if s.err != nil {
+		return false
+	}
+	results, err := cond.Run(s.resp)
+	return err == nil && len(results) > 0
+}
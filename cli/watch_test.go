@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/alexej-v/grpc_cli/diff"
+)
+
+func TestMarkedJSON(t *testing.T) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(`{"job": {"id": "7", "progress": 40}, "tags": ["a", {"b": 1}], "empty": {}}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	markedJSON(&b, "", v, "", "", nil)
+	want, _ := json.MarshalIndent(v, "", "  ")
+	if b.String() != string(want) {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	markedJSON(&b, "", v, "", "", map[string]diff.Kind{"job.progress": diff.Changed, "tags[1]": diff.Added})
+	for _, line := range []string{
+		"    \033[33m\"progress\": 40\033[39m\n",
+		"    \033[32m{\n      \"b\": 1\n    }\033[39m\n",
+		"    \"id\": \"7\",\n",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("%q not found in\n%s", line, b.String())
+		}
+	}
+}
@@ -6,11 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"strings"
 	"sync"
-	"time"
 
+	"github.com/alexej-v/grpc_cli/auth"
 	"github.com/alexej-v/grpc_cli/certs"
+	"github.com/alexej-v/grpc_cli/config"
 
 	"github.com/jhump/protoreflect/grpcreflect"
 	"github.com/pkg/errors"
@@ -31,7 +33,9 @@ const (
 
 type Client interface {
 	Headers() Headers
-	Invoke(ctx context.Context, fqrn string, req, resp interface{}) error
+	Invoke(ctx context.Context, fqrn string, req, resp interface{}, opts ...grpc.CallOption) error
+	NewStream(ctx context.Context, desc *grpc.StreamDesc, fqrn string, opts ...grpc.CallOption) (grpc.ClientStream, error)
+	Close() error
 }
 
 type client struct {
@@ -47,13 +51,49 @@ type ClientCfg struct {
 	UseReflection bool
 	WithTLS       bool
 	Certs         certs.Certs
+	Credentials   credentials.PerRPCCredentials
+	// Compression is the request compressor, "gzip" or "none".
+	Compression string
+	// Balancer is the load balancing policy of targets resolving to several
+	// addresses, pick_first by default.
+	Balancer string
+	Dial     config.Dial
+	// Protocol is one of Protocols, gRPC by default.
+	Protocol string
+	// Retry is the policy of unary and server streaming calls.
+	Retry config.Retry
 }
 
 func NewClient(cfg *ClientCfg) (cli Client, err error) {
-	var opts []grpc.DialOption
+	if cli, err = newClient(cfg); err != nil {
+		return nil, err
+	}
+	retrying, err := withRetry(cli, cfg.Retry)
+	if err != nil {
+		cli.Close()
+		return nil, err
+	}
+	return retrying, nil
+}
+
+// newClient returns a client of the protocol.
+func newClient(cfg *ClientCfg) (Client, error) {
+	switch cfg.Protocol {
+	case "", ProtocolGRPC:
+	case ProtocolGRPCWeb, ProtocolGRPCWebText:
+		return newWebClient(cfg)
+	case ProtocolConnect, ProtocolConnectJSON:
+		return newConnectClient(cfg)
+	default:
+		return nil, CheckProtocol(cfg.Protocol)
+	}
 	var tlsCfg tls.Config
 	var conn *grpc.ClientConn
 
+	target, authority, opts, err := dialTarget(cfg.Addr, cfg.Balancer)
+	if err != nil {
+		return nil, err
+	}
 	if !cfg.WithTLS {
 		opts = append(opts, grpc.WithInsecure())
 	} else {
@@ -64,18 +104,38 @@ func NewClient(cfg *ClientCfg) (cli Client, err error) {
 			tlsCfg.Certificates = append(tlsCfg.Certificates, cfg.Certs.Cert())
 		}
 		creds := credentials.NewTLS(&tlsCfg)
-		if cfg.ServerName != "" {
-			if err = creds.OverrideServerName(cfg.ServerName); err != nil {
+		serverName := cfg.ServerName
+		if cfg.Dial.Authority != "" {
+			authority = cfg.Dial.Authority
+		}
+		if serverName == "" && authority != "" {
+			serverName = authority
+			if host, _, err := net.SplitHostPort(authority); err == nil {
+				serverName = host
+			}
+		}
+		if serverName != "" {
+			if err = creds.OverrideServerName(serverName); err != nil {
 				return nil, errors.Wrap(err, "failed to override server name")
 			}
 		}
 		opts = append(opts, grpc.WithTransportCredentials(creds))
 	}
+	if cfg.Credentials != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(cfg.Credentials))
+	}
+	if gzip, err := compressed(cfg.Compression); err != nil {
+		return nil, err
+	} else if gzip {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(CompressionGzip)))
+	}
+	opts = append(opts, grpc.WithStatsHandler(statsHandler{}))
+	opts = append(opts, dialOptions(cfg.Dial)...)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(10*time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout(cfg.Dial))
 	defer cancel()
 
-	if conn, err = grpc.DialContext(ctx, cfg.Addr, opts...); err != nil {
+	if conn, err = grpc.DialContext(ctx, target, opts...); err != nil {
 		return nil, errors.Wrap(err, "failed to dial to gRPC server")
 	}
 	newClient := &client{conn: conn, headers: Headers{}}
@@ -87,6 +147,33 @@ func NewClient(cfg *ClientCfg) (cli Client, err error) {
 	return newClient, nil
 }
 
+// NewClientFromConfig creates a client for the server, loading its
+// certificates when TLS is enabled.
+func NewClientFromConfig(srv *config.Server) (Client, error) {
+	cfg := &ClientCfg{
+		Addr:          srv.Address(),
+		ServerName:    srv.Name,
+		UseReflection: srv.Reflection,
+		WithTLS:       srv.TLS,
+		Compression:   srv.Compression,
+		Balancer:      srv.Balancer,
+		Dial:          srv.Dial,
+		Protocol:      srv.Protocol,
+		Retry:         srv.Retry,
+	}
+	if creds := auth.FromConfig(&srv.Auth); creds != nil {
+		cfg.Credentials = creds
+	}
+	if srv.TLS {
+		crts, err := certs.Define(srv.CACert, srv.Cert, srv.CertKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load certificates")
+		}
+		cfg.Certs = crts
+	}
+	return NewClient(cfg)
+}
+
 func NewClientOnce(cfg *ClientCfg) (cli Client, err error) {
 	once.Do(func() {
 		defaultCli, err = NewClient(cfg)
@@ -98,14 +185,29 @@ func (c *client) Headers() Headers {
 	return c.headers
 }
 
-func (c *client) Invoke(ctx context.Context, fqrn string, req, resp interface{}) error {
+func (c *client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *client) Invoke(ctx context.Context, fqrn string, req, resp interface{}, opts ...grpc.CallOption) error {
 	method, err := fullQualifiedRPCNameToMethod(fqrn)
 	if err != nil {
 		return err
 	}
 	// logRequest(req)
 	connectBackOff(c.conn)
-	return c.conn.Invoke(ctx, method, req, resp)
+	return c.conn.Invoke(ctx, method, req, resp, opts...)
+}
+
+func (c *client) NewStream(
+	ctx context.Context, desc *grpc.StreamDesc, fqrn string, opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	method, err := fullQualifiedRPCNameToMethod(fqrn)
+	if err != nil {
+		return nil, err
+	}
+	connectBackOff(c.conn)
+	return c.conn.NewStream(ctx, desc, method, opts...)
 }
 
 func fullQualifiedRPCNameToMethod(name string) (string, error) {
@@ -0,0 +1,223 @@
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/config"
+	"github.com/alexej-v/grpc_cli/mock"
+	"github.com/alexej-v/grpc_cli/proto"
+
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testProto = `syntax = "proto3";
+package test.client;
+
+message GetOrderRequest {
+  string order_id = 1;
+}
+
+message Order {
+  string order_id = 1;
+  string status = 2;
+}
+
+service Orders {
+  rpc GetOrder(GetOrderRequest) returns (Order);
+}
+`
+
+const testStubs = `[
+  {"method": "test.client.Orders/GetOrder", "match": {"order_id": "1"}, "response": {"order_id": "1", "status": "DONE"}},
+  {"method": "test.client.Orders/GetOrder", "response": {"status": "NEW"}}
+]`
+
+// loadTestSpec writes the test proto and stubs of the mock server to a
+// temporary directory, removed by the returned function.
+func loadTestSpec(t *testing.T) (proto.Spec, *mock.Stubs, func()) {
+	dir, err := ioutil.TempDir("", "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "orders.proto"), []byte(testProto), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "orders.json"), []byte(testStubs), 0600); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := proto.Parse([]string{"orders.proto"}, []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stubs, err := mock.LoadStubs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec, stubs, func() { os.RemoveAll(dir) }
+}
+
+// startServer starts a mock server with the options and a client with the
+// settings.
+func startServer(t *testing.T, cfg client.ClientCfg, opts ...grpc.ServerOption) (client.Client, proto.Spec, func()) {
+	spec, stubs, cleanup := loadTestSpec(t)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := mock.NewServer(spec, stubs, false, opts...)
+	go srv.Serve(lis)
+
+	cfg.Addr = lis.Addr().String()
+	cli, err := client.NewClient(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cli, spec, func() {
+		cli.Close()
+		srv.Stop()
+		cleanup()
+	}
+}
+
+func getOrder(
+	ctx context.Context, t *testing.T, cli client.Client, spec proto.Spec, orderID string,
+) (*dynamic.Message, error) {
+	rpc, err := spec.RPC("test.client", "Orders", "GetOrder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := rpc.RequestType.New()
+	req.(*dynamic.Message).SetFieldByName("order_id", orderID)
+	resp, _ := rpc.ResponseType.New()
+	err = cli.Invoke(ctx, rpc.FullyQualifiedName, req, resp)
+	return resp.(*dynamic.Message), err
+}
+
+func TestCompression(t *testing.T) {
+	for _, tc := range []struct {
+		compression, want string
+	}{
+		{client.CompressionGzip, "gzip"},
+		{client.CompressionNone, "identity"},
+	} {
+		cli, spec, stop := startServer(t, client.ClientCfg{Compression: tc.compression})
+		stats := new(client.CallStats)
+		resp, err := getOrder(client.WithStats(context.Background(), stats), t, cli, spec, "1")
+		stop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resp.GetFieldByName("status"); got != "DONE" {
+			t.Errorf("%s: got status %q, want DONE", tc.compression, got)
+		}
+		if got := stats.ResponseEncoding(); got != tc.want {
+			t.Errorf("%s: response encoding %s, want %s", tc.compression, got, tc.want)
+		}
+		if compressed := stats.RequestWireBytes != stats.RequestBytes; compressed != (tc.want == "gzip") {
+			t.Errorf("%s: request %d bytes, %d on the wire", tc.compression, stats.RequestBytes, stats.RequestWireBytes)
+		}
+	}
+}
+
+func TestTargets(t *testing.T) {
+	spec, stubs, cleanup := loadTestSpec(t)
+	defer cleanup()
+	dir, err := ioutil.TempDir("", "client-sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var stops []func()
+	listen := func(network, addr string) string {
+		lis, err := net.Listen(network, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := mock.NewServer(spec, stubs, false)
+		go srv.Serve(lis)
+		stops = append(stops, srv.Stop)
+		return lis.Addr().String()
+	}
+	sock := listen("unix", filepath.Join(dir, "mock.sock"))
+	first, second := listen("tcp", "127.0.0.1:0"), listen("tcp", "127.0.0.1:0")
+	defer func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}()
+
+	for _, tc := range []struct {
+		target, balancer string
+	}{
+		{"unix://" + sock, ""},
+		{"unix:" + sock, ""},
+		{first + "," + second, client.BalancerRoundRobin},
+		{first + "," + second, client.BalancerPickFirst},
+		{"dns:///" + first, ""},
+	} {
+		cli, err := client.NewClient(&client.ClientCfg{Addr: tc.target, Balancer: tc.balancer})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.target, err)
+		}
+		for i := 0; i < 3; i++ {
+			if _, err = getOrder(context.Background(), t, cli, spec, "1"); err != nil {
+				t.Errorf("%s %s: %v", tc.target, tc.balancer, err)
+			}
+		}
+		cli.Close()
+	}
+
+	if _, err = client.NewClient(&client.ClientCfg{Addr: first, Balancer: "random"}); err == nil {
+		t.Error("unsupported balancer accepted")
+	}
+}
+
+func TestDialSettings(t *testing.T) {
+	var md metadata.MD
+	capture := grpc.UnaryInterceptor(func(
+		ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		md, _ = metadata.FromIncomingContext(ctx)
+		return handler(ctx, req)
+	})
+	cli, spec, stop := startServer(t, client.ClientCfg{Dial: config.Dial{UserAgent: "test", Authority: "orders.local"}}, capture)
+	_, err := getOrder(context.Background(), t, cli, spec, "1")
+	stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := md.Get("user-agent"); len(got) != 1 || !strings.HasPrefix(got[0], "test grpc-go/") {
+		t.Errorf("user agent %v, want test prepended to the gRPC one", got)
+	}
+	if got := md.Get(":authority"); len(got) != 1 || got[0] != "orders.local" {
+		t.Errorf("authority %v, want orders.local", got)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		dial    config.Dial
+		orderID string
+	}{
+		{"response over the receive limit", config.Dial{MaxRecvMsgSize: 4}, "1"},
+		{"request over the send limit", config.Dial{MaxSendMsgSize: 4}, "12345678"},
+	} {
+		cli, spec, stop := startServer(t, client.ClientCfg{Dial: tc.dial})
+		_, err := getOrder(context.Background(), t, cli, spec, tc.orderID)
+		stop()
+		if st := status.Convert(err); st.Code() != codes.ResourceExhausted {
+			t.Errorf("%s: got %v, want ResourceExhausted", tc.name, err)
+		}
+	}
+}
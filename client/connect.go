@@ -0,0 +1,395 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/pkg/errors"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Flags of Connect streaming envelopes.
+const (
+	connectCompressed = 0x01
+	connectEndStream  = 0x02
+)
+
+// connectTrailerPrefix prefixes trailers of unary responses sent as headers.
+const connectTrailerPrefix = "trailer-"
+
+// connectCodes are names of codes in Connect errors.
+var connectCodes = map[string]codes.Code{
+	"canceled":            codes.Canceled,
+	"unknown":             codes.Unknown,
+	"invalid_argument":    codes.InvalidArgument,
+	"deadline_exceeded":   codes.DeadlineExceeded,
+	"not_found":           codes.NotFound,
+	"already_exists":      codes.AlreadyExists,
+	"permission_denied":   codes.PermissionDenied,
+	"resource_exhausted":  codes.ResourceExhausted,
+	"failed_precondition": codes.FailedPrecondition,
+	"aborted":             codes.Aborted,
+	"out_of_range":        codes.OutOfRange,
+	"unimplemented":       codes.Unimplemented,
+	"internal":            codes.Internal,
+	"unavailable":         codes.Unavailable,
+	"data_loss":           codes.DataLoss,
+	"unauthenticated":     codes.Unauthenticated,
+}
+
+// connectClient calls methods with the Connect protocol, messages are
+// encoded as binary protobuf or as JSON. Requests of streams are sent when
+// the sending side is closed, so bidi streams are half-duplex.
+type connectClient struct {
+	*httpTransport
+	json    bool
+	headers Headers
+}
+
+func newConnectClient(cfg *ClientCfg) (Client, error) {
+	t, err := newHTTPTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &connectClient{httpTransport: t, json: cfg.Protocol == ProtocolConnectJSON, headers: Headers{}}, nil
+}
+
+func (c *connectClient) Headers() Headers {
+	return c.headers
+}
+
+func (c *connectClient) codec() string {
+	if c.json {
+		return "json"
+	}
+	return "proto"
+}
+
+func (c *connectClient) marshal(m interface{}) ([]byte, error) {
+	if !c.json {
+		return marshalMessage(m)
+	}
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil, errors.Errorf("%T is not a proto message", m)
+	}
+	var buf bytes.Buffer
+	if err := new(jsonpb.Marshaler).Marshal(&buf, msg); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal request")
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *connectClient) unmarshal(b []byte, m interface{}) error {
+	if !c.json {
+		return unmarshalMessage(b, m)
+	}
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return errors.Errorf("%T is not a proto message", m)
+	}
+	u := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	if err := u.Unmarshal(bytes.NewReader(b), msg); err != nil {
+		return status.Errorf(codes.Internal, "failed to unmarshal response: %v", err)
+	}
+	return nil
+}
+
+func (c *connectClient) request(ctx context.Context, method, contentType string, body []byte) (*http.Request, error) {
+	req, err := c.newRequest(ctx, method, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Connect-Protocol-Version", "1")
+	if deadline, ok := ctx.Deadline(); ok {
+		ms := time.Until(deadline) / time.Millisecond
+		if ms < 1 {
+			ms = 1
+		}
+		req.Header.Set("Connect-Timeout-Ms", strconv.FormatInt(int64(ms), 10))
+	}
+	return req, nil
+}
+
+func (c *connectClient) Invoke(ctx context.Context, fqrn string, req, resp interface{}, opts ...grpc.CallOption) error {
+	method, err := methodPath(fqrn)
+	if err != nil {
+		return err
+	}
+	headerAddr, trailerAddr := callOptions(opts)
+	b, err := c.marshal(req)
+	if err != nil {
+		return err
+	}
+	wire, err := c.compress(b)
+	if err != nil {
+		return err
+	}
+	httpReq, err := c.request(ctx, method, "application/"+c.codec(), wire)
+	if err != nil {
+		return err
+	}
+	// Responses are decompressed here, not by the HTTP client, to count
+	// their wire sizes.
+	httpReq.Header.Set("Accept-Encoding", CompressionGzip)
+	if c.gzip {
+		httpReq.Header.Set("Content-Encoding", CompressionGzip)
+	}
+	addStats(ctx, len(b), len(wire), 0, 0)
+
+	httpResp, err := c.client.Do(httpReq)
+	if err != nil {
+		return transportError(ctx, err)
+	}
+	defer httpResp.Body.Close()
+
+	header, trailer := metadata.MD{}, metadata.MD{}
+	for k, vals := range headerMD(httpResp.Header, "content-type", "content-length", "content-encoding", "date", "server") {
+		if strings.HasPrefix(k, connectTrailerPrefix) {
+			trailer[strings.TrimPrefix(k, connectTrailerPrefix)] = vals
+			continue
+		}
+		header[k] = vals
+	}
+	if headerAddr != nil {
+		*headerAddr = header
+	}
+	if trailerAddr != nil {
+		*trailerAddr = trailer
+	}
+
+	wire, err = ioutil.ReadAll(io.LimitReader(httpResp.Body, int64(c.maxRecvMsg)+1))
+	if err != nil {
+		return transportError(ctx, err)
+	}
+	if len(wire) > c.maxRecvMsg {
+		return status.Errorf(codes.ResourceExhausted, "received message larger than max (%d)", c.maxRecvMsg)
+	}
+	body, err := decompress(httpResp.Header.Get("Content-Encoding"), wire, c.maxRecvMsg)
+	if httpResp.StatusCode != http.StatusOK {
+		if err != nil {
+			body = wire
+		}
+		return connectErrorStatus(httpResp, body).Err()
+	}
+	if err != nil {
+		return err
+	}
+	addStats(ctx, 0, 0, len(body), len(wire))
+	return c.unmarshal(body, resp)
+}
+
+func (c *connectClient) NewStream(
+	ctx context.Context, desc *grpc.StreamDesc, fqrn string, opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	method, err := methodPath(fqrn)
+	if err != nil {
+		return nil, err
+	}
+	s := &connectStream{ctx: ctx, c: c, method: method, clientStreams: desc.ClientStreams}
+	s.headerAddr, s.trailerAddr = callOptions(opts)
+	return s, nil
+}
+
+// connectStream buffers request envelopes until the sending side is closed
+// and then reads response envelopes.
+type connectStream struct {
+	ctx           context.Context
+	c             *connectClient
+	method        string
+	clientStreams bool
+	headerAddr    *metadata.MD
+	trailerAddr   *metadata.MD
+
+	requests bytes.Buffer
+	sent     bool
+	resp     *http.Response
+	body     *bufio.Reader
+	header   metadata.MD
+	trailer  metadata.MD
+	// err is the final status, io.EOF after a successful call.
+	err error
+}
+
+func (s *connectStream) SendMsg(m interface{}) error {
+	if s.sent {
+		return status.Error(codes.Internal, "SendMsg called after CloseSend")
+	}
+	b, err := s.c.marshal(m)
+	if err != nil {
+		return err
+	}
+	wire, err := s.c.compress(b)
+	if err != nil {
+		return err
+	}
+	var flag byte
+	if s.c.gzip {
+		flag = connectCompressed
+	}
+	s.requests.Write(frame(flag, wire))
+	addStats(s.ctx, len(b), len(wire), 0, 0)
+	if !s.clientStreams {
+		return s.send()
+	}
+	return nil
+}
+
+func (s *connectStream) CloseSend() error {
+	if s.sent {
+		return nil
+	}
+	return s.send()
+}
+
+// send posts the buffered requests and reads response headers.
+func (s *connectStream) send() error {
+	s.sent = true
+	req, err := s.c.request(s.ctx, s.method, "application/connect+"+s.c.codec(), s.requests.Bytes())
+	if err != nil {
+		return s.finish(err)
+	}
+	req.Header.Set("Connect-Accept-Encoding", CompressionGzip)
+	if s.c.gzip {
+		req.Header.Set("Connect-Content-Encoding", CompressionGzip)
+	}
+	resp, err := s.c.client.Do(req)
+	if err != nil {
+		return s.finish(transportError(s.ctx, err))
+	}
+	s.resp = resp
+	s.header = headerMD(resp.Header, "content-type", "content-length", "date", "server",
+		"connect-content-encoding", "connect-accept-encoding")
+	if s.headerAddr != nil {
+		*s.headerAddr = s.header
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return s.finish(connectErrorStatus(resp, body).Err())
+	}
+	s.body = bufio.NewReader(resp.Body)
+	return nil
+}
+
+func (s *connectStream) RecvMsg(m interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	if !s.sent {
+		if err := s.send(); err != nil {
+			return err
+		}
+	}
+	flag, b, err := readFrame(s.body, s.c.maxRecvMsg)
+	if err == io.EOF {
+		return s.finish(status.Error(codes.Internal, "response ended without end-stream message"))
+	}
+	if err != nil {
+		return s.finish(err)
+	}
+	wire := len(b)
+	if flag&connectCompressed != 0 {
+		if b, err = decompress(s.resp.Header.Get("Connect-Content-Encoding"), b, s.c.maxRecvMsg); err != nil {
+			return s.finish(err)
+		}
+	}
+	if flag&connectEndStream != 0 {
+		var end struct {
+			Error    *connectError       `json:"error"`
+			Metadata map[string][]string `json:"metadata"`
+		}
+		if err = json.Unmarshal(b, &end); err != nil {
+			return s.finish(status.Errorf(codes.Internal, "invalid end-stream message: %v", err))
+		}
+		s.trailer = headerMD(http.Header(end.Metadata))
+		if end.Error != nil {
+			return s.finish(end.Error.status(codes.Unknown).Err())
+		}
+		return s.finish(nil)
+	}
+	addStats(s.ctx, 0, 0, len(b), wire)
+	return s.c.unmarshal(b, m)
+}
+
+// finish records the final status of the call, nil if it is ok.
+func (s *connectStream) finish(err error) error {
+	if s.trailerAddr != nil {
+		*s.trailerAddr = s.trailer
+	}
+	if s.resp != nil {
+		s.resp.Body.Close()
+	}
+	if err == nil {
+		s.err = io.EOF
+		return io.EOF
+	}
+	s.err = err
+	return err
+}
+
+func (s *connectStream) Header() (metadata.MD, error) {
+	return s.header, nil
+}
+
+func (s *connectStream) Trailer() metadata.MD {
+	return s.trailer
+}
+
+func (s *connectStream) Context() context.Context {
+	return s.ctx
+}
+
+// connectError is the JSON error of Connect responses.
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"details"`
+}
+
+// status converts the error, fallback is used for unknown codes.
+func (e *connectError) status(fallback codes.Code) *status.Status {
+	code, ok := connectCodes[e.Code]
+	if !ok {
+		code = fallback
+	}
+	st := &spb.Status{Code: int32(code), Message: e.Message}
+	for _, d := range e.Details {
+		// Values are base64 encoded, padding is optional.
+		b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(d.Value, "="))
+		if err != nil {
+			continue
+		}
+		st.Details = append(st.Details, &any.Any{TypeUrl: "type.googleapis.com/" + d.Type, Value: b})
+	}
+	return status.FromProto(st)
+}
+
+// connectErrorStatus returns the status of a failed response, from its JSON
+// error or from the HTTP status of proxy errors.
+func connectErrorStatus(resp *http.Response, body []byte) *status.Status {
+	fallback := httpStatusCode(resp.StatusCode)
+	var e connectError
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && json.Unmarshal(body, &e) == nil && e.Code != "" {
+		return e.status(fallback)
+	}
+	return status.Newf(fallback, "unexpected HTTP response %s: %s", resp.Status, bytes.TrimSpace(body))
+}
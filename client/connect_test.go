@@ -0,0 +1,220 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// connectHandler serves test.Echo with the Connect protocol: Say echoes,
+// Fail returns an error, Repeat streams three echoes and Join concatenates
+// streamed requests.
+func connectHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		isJSON := strings.HasSuffix(contentType, "json")
+		decode := func(b []byte) string {
+			v := new(wrappers.StringValue)
+			var err error
+			if isJSON {
+				err = jsonpb.Unmarshal(bytes.NewReader(b), v)
+			} else {
+				err = proto.Unmarshal(b, v)
+			}
+			if err != nil {
+				t.Errorf("failed to decode request: %v", err)
+			}
+			return v.Value
+		}
+		encode := func(s string) []byte {
+			v := &wrappers.StringValue{Value: s}
+			if isJSON {
+				b, _ := new(jsonpb.Marshaler).MarshalToString(v)
+				return []byte(b)
+			}
+			b, _ := proto.Marshal(v)
+			return b
+		}
+		if r.Header.Get("Connect-Protocol-Version") != "1" {
+			t.Errorf("missing Connect-Protocol-Version")
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Id", r.Header.Get("X-Id"))
+		// Responses are compressed like requests.
+		unaryGzip := r.Header.Get("Content-Encoding") == CompressionGzip
+		streamGzip := r.Header.Get("Connect-Content-Encoding") == CompressionGzip
+		if unaryGzip {
+			var err error
+			if body, err = decompress(CompressionGzip, body, defaultMaxRecvMsgSize); err != nil {
+				t.Errorf("failed to decompress request: %v", err)
+			}
+		}
+		envelope := func(b []byte) []byte {
+			if streamGzip {
+				return frame(connectCompressed, gzipped(t, b))
+			}
+			return frame(0, b)
+		}
+
+		switch r.URL.Path {
+		case "/test.Echo/Say":
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Trailer-X-Trailer", "done")
+			if unaryGzip {
+				w.Header().Set("Content-Encoding", CompressionGzip)
+				w.Write(gzipped(t, encode(decode(body))))
+				return
+			}
+			w.Write(encode(decode(body)))
+		case "/test.Echo/Fail":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			// RetryInfo with a 1s delay.
+			w.Write([]byte(`{"code":"not_found","message":"no ` + decode(body) + `",` +
+				`"details":[{"type":"google.rpc.RetryInfo","value":"CgIIAQ"}]}`))
+		case "/test.Echo/Repeat", "/test.Echo/Join":
+			w.Header().Set("Content-Type", contentType)
+			var values []string
+			if streamGzip {
+				w.Header().Set("Connect-Content-Encoding", CompressionGzip)
+			}
+			for r := bytes.NewReader(body); ; {
+				flag, b, err := readFrame(r, defaultMaxRecvMsgSize)
+				if err != nil {
+					break
+				}
+				if flag&connectCompressed != 0 {
+					if b, err = decompress(CompressionGzip, b, defaultMaxRecvMsgSize); err != nil {
+						t.Errorf("failed to decompress request: %v", err)
+					}
+				}
+				values = append(values, decode(b))
+			}
+			if r.URL.Path == "/test.Echo/Join" {
+				w.Write(envelope(encode(strings.Join(values, " "))))
+			} else {
+				for i := 0; i < 3; i++ {
+					w.Write(envelope(encode(values[0])))
+				}
+			}
+			w.Write(frame(connectEndStream, []byte(`{"metadata":{"x-trailer":["done"]}}`)))
+		}
+	})
+}
+
+func TestConnectClient(t *testing.T) {
+	srv := httptest.NewServer(connectHandler(t))
+	defer srv.Close()
+
+	for _, protocol := range []string{ProtocolConnect, ProtocolConnectJSON} {
+		for _, compression := range []string{CompressionNone, CompressionGzip} {
+			t.Run(protocol+"/"+compression, func(t *testing.T) {
+				testConnectClient(t, srv.URL, protocol, compression)
+			})
+		}
+	}
+}
+
+func testConnectClient(t *testing.T, url, protocol, compression string) {
+	cli, err := NewClient(&ClientCfg{
+		Addr: strings.TrimPrefix(url, "http://"), Protocol: protocol, Compression: compression,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-id", "42")
+
+	var header, trailer metadata.MD
+	resp := new(wrappers.StringValue)
+	stats := new(CallStats)
+	err = cli.Invoke(WithStats(ctx, stats), "test.Echo.Say", &wrappers.StringValue{Value: strings.Repeat("a", 100)}, resp,
+		grpc.Header(&header), grpc.Trailer(&trailer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Value != strings.Repeat("a", 100) {
+		t.Errorf("unexpected response %q", resp.Value)
+	}
+	checkEncoding(t, stats, compression)
+	if got := header.Get("x-id"); len(got) != 1 || got[0] != "42" {
+		t.Errorf("unexpected header x-id %v", got)
+	}
+	if got := trailer.Get("x-trailer"); len(got) != 1 || got[0] != "done" {
+		t.Errorf("unexpected trailer x-trailer %v", got)
+	}
+
+	err = cli.Invoke(ctx, "test.Echo.Fail", &wrappers.StringValue{Value: "luck"}, resp)
+	st := status.Convert(err)
+	if st.Code() != codes.NotFound || st.Message() != "no luck" {
+		t.Errorf("unexpected status %v", err)
+	}
+	if details := st.Details(); len(details) != 1 {
+		t.Errorf("expected 1 detail, got %v", details)
+	} else if info, ok := details[0].(*errdetails.RetryInfo); !ok || info.RetryDelay.GetSeconds() != 1 {
+		t.Errorf("unexpected detail %v", details[0])
+	}
+
+	stats = new(CallStats)
+	s, err := cli.NewStream(WithStats(ctx, stats), &grpc.StreamDesc{ServerStreams: true}, "test.Echo.Repeat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.SendMsg(&wrappers.StringValue{Value: strings.Repeat("b", 100)}); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for {
+		err = s.RecvMsg(resp)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("expected 3 responses, got %d", n)
+	}
+	checkEncoding(t, stats, compression)
+	if got := s.Trailer().Get("x-trailer"); len(got) != 1 || got[0] != "done" {
+		t.Errorf("unexpected stream trailer x-trailer %v", got)
+	}
+
+	s, err = cli.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true}, "test.Echo.Join")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"a", "b", "c"} {
+		if err = s.SendMsg(&wrappers.StringValue{Value: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = s.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.RecvMsg(resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Value != "a b c" {
+		t.Errorf("unexpected joined response %q", resp.Value)
+	}
+	if err = s.RecvMsg(resp); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
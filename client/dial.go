@@ -0,0 +1,64 @@
+package client
+
+import (
+	"time"
+
+	"github.com/alexej-v/grpc_cli/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/keepalive"
+)
+
+// dialOptions converts the settings to dial options, unset ones keep gRPC
+// defaults.
+func dialOptions(d config.Dial) []grpc.DialOption {
+	var opts []grpc.DialOption
+	var callOpts []grpc.CallOption
+	if d.MaxRecvMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(d.MaxRecvMsgSize))
+	}
+	if d.MaxSendMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(d.MaxSendMsgSize))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	// Pings are sent only with an interval, gRPC would ping every 10s
+	// for a timeout alone.
+	if d.KeepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                time.Duration(d.KeepaliveTime),
+			Timeout:             time.Duration(d.KeepaliveTimeout),
+			PermitWithoutStream: d.KeepaliveWithoutCalls,
+		}))
+	}
+	if d.InitialWindowSize > 0 {
+		opts = append(opts, grpc.WithInitialWindowSize(d.InitialWindowSize))
+	}
+	if d.InitialConnWindowSize > 0 {
+		opts = append(opts, grpc.WithInitialConnWindowSize(d.InitialConnWindowSize))
+	}
+	if d.UserAgent != "" {
+		opts = append(opts, grpc.WithUserAgent(d.UserAgent))
+	}
+	if d.Authority != "" {
+		opts = append(opts, grpc.WithAuthority(d.Authority))
+	}
+
+	params := grpc.ConnectParams{Backoff: backoff.DefaultConfig, MinConnectTimeout: dialTimeout(d)}
+	if d.BackoffBaseDelay > 0 {
+		params.Backoff.BaseDelay = time.Duration(d.BackoffBaseDelay)
+	}
+	if d.BackoffMaxDelay > 0 {
+		params.Backoff.MaxDelay = time.Duration(d.BackoffMaxDelay)
+	}
+	return append(opts, grpc.WithConnectParams(params))
+}
+
+func dialTimeout(d config.Dial) time.Duration {
+	if d.Timeout > 0 {
+		return time.Duration(d.Timeout)
+	}
+	return config.DefaultDialTimeout
+}
@@ -0,0 +1,360 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Flags of gRPC-Web frames.
+const (
+	webDataFrame    = 0x00
+	webTrailerFrame = 0x80
+	webCompressed   = 0x01
+)
+
+// webClient calls methods with the gRPC-Web protocol over HTTP/1.1, in
+// binary or base64 text mode. Client and bidi streaming are not supported
+// by the protocol.
+type webClient struct {
+	*httpTransport
+	text    bool
+	headers Headers
+}
+
+func newWebClient(cfg *ClientCfg) (Client, error) {
+	t, err := newHTTPTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &webClient{httpTransport: t, text: cfg.Protocol == ProtocolGRPCWebText, headers: Headers{}}, nil
+}
+
+func (c *webClient) Headers() Headers {
+	return c.headers
+}
+
+func (c *webClient) contentType() string {
+	if c.text {
+		return "application/grpc-web-text+proto"
+	}
+	return "application/grpc-web+proto"
+}
+
+func (c *webClient) Invoke(ctx context.Context, fqrn string, req, resp interface{}, opts ...grpc.CallOption) error {
+	s, err := c.NewStream(ctx, &grpc.StreamDesc{}, fqrn, opts...)
+	if err != nil {
+		return err
+	}
+	return invokeStream(s, req, resp)
+}
+
+// invokeStream makes a unary call on the stream of an HTTP based protocol.
+func invokeStream(s grpc.ClientStream, req, resp interface{}) error {
+	if err := s.SendMsg(req); err != nil {
+		return err
+	}
+	if err := s.RecvMsg(resp); err != nil {
+		if err == io.EOF {
+			return status.Error(codes.Internal, "no response message")
+		}
+		return err
+	}
+	// Only trailers may follow, resp is not changed by them.
+	if err := s.RecvMsg(resp); err != io.EOF {
+		if err == nil {
+			return status.Error(codes.Internal, "more than one response message")
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *webClient) NewStream(
+	ctx context.Context, desc *grpc.StreamDesc, fqrn string, opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	if desc.ClientStreams {
+		return nil, status.Error(codes.Unimplemented, "gRPC-Web does not support client streaming")
+	}
+	method, err := methodPath(fqrn)
+	if err != nil {
+		return nil, err
+	}
+	s := &webStream{ctx: ctx, c: c, method: method}
+	s.headerAddr, s.trailerAddr = callOptions(opts)
+	return s, nil
+}
+
+// webStream is a call sending one request and reading response frames.
+type webStream struct {
+	ctx         context.Context
+	c           *webClient
+	method      string
+	headerAddr  *metadata.MD
+	trailerAddr *metadata.MD
+
+	resp    *http.Response
+	body    *bufio.Reader
+	header  metadata.MD
+	trailer metadata.MD
+	// err is the final status, io.EOF after a successful call.
+	err error
+}
+
+func (s *webStream) SendMsg(m interface{}) error {
+	if s.resp != nil || s.err != nil {
+		return status.Error(codes.Internal, "gRPC-Web calls send a single request")
+	}
+	b, err := marshalMessage(m)
+	if err != nil {
+		return err
+	}
+	wire, flag := b, byte(webDataFrame)
+	if s.c.gzip {
+		if wire, err = s.c.compress(b); err != nil {
+			return err
+		}
+		flag |= webCompressed
+	}
+	body := frame(flag, wire)
+	if s.c.text {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+	req, err := s.c.newRequest(s.ctx, s.method, s.c.contentType(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", s.c.contentType())
+	req.Header.Set("X-Grpc-Web", "1")
+	req.Header.Set("Grpc-Accept-Encoding", CompressionGzip)
+	if s.c.gzip {
+		req.Header.Set("Grpc-Encoding", CompressionGzip)
+	}
+	if deadline, ok := s.ctx.Deadline(); ok {
+		req.Header.Set("Grpc-Timeout", encodeTimeout(time.Until(deadline)))
+	}
+	addStats(s.ctx, len(b), len(wire), 0, 0)
+
+	resp, err := s.c.client.Do(req)
+	if err != nil {
+		return s.finish(transportError(s.ctx, err))
+	}
+	s.resp = resp
+	s.header = headerMD(resp.Header, "content-type", "content-length", "date", "server", "grpc-status", "grpc-message",
+		"grpc-encoding", "grpc-accept-encoding")
+	if s.headerAddr != nil {
+		*s.headerAddr = s.header
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/grpc-web") {
+		// Errors of proxies have no gRPC status.
+		defer resp.Body.Close()
+		if st := headerStatus(resp.Header); st != nil {
+			return s.finish(st.Err())
+		}
+		snippet, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 256))
+		return s.finish(status.Errorf(httpStatusCode(resp.StatusCode),
+			"unexpected HTTP response %s: %s", resp.Status, bytes.TrimSpace(snippet)))
+	}
+	var r io.Reader = resp.Body
+	if s.c.text {
+		r = &base64Reader{r: resp.Body}
+	}
+	s.body = bufio.NewReader(r)
+	return nil
+}
+
+func (s *webStream) RecvMsg(m interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.body == nil {
+		return status.Error(codes.Internal, "RecvMsg called before SendMsg")
+	}
+	flag, b, err := readFrame(s.body, s.c.maxRecvMsg)
+	if err == io.EOF {
+		// A trailers-only response has the status in headers.
+		if st := headerStatus(s.resp.Header); st != nil {
+			return s.finish(st.Err())
+		}
+		return s.finish(status.Error(codes.Internal, "response ended without trailers"))
+	}
+	if err != nil {
+		return s.finish(err)
+	}
+	wire := len(b)
+	if flag&webCompressed != 0 {
+		if b, err = decompress(s.resp.Header.Get("Grpc-Encoding"), b, s.c.maxRecvMsg); err != nil {
+			return s.finish(err)
+		}
+	}
+	if flag&webTrailerFrame != 0 {
+		s.trailer = parseTrailer(b)
+		st := trailerStatus(s.trailer)
+		delete(s.trailer, "grpc-status")
+		delete(s.trailer, "grpc-message")
+		delete(s.trailer, "grpc-status-details-bin")
+		return s.finish(st.Err())
+	}
+	addStats(s.ctx, 0, 0, len(b), wire)
+	return unmarshalMessage(b, m)
+}
+
+// finish records the final status of the call, nil if it is ok.
+func (s *webStream) finish(err error) error {
+	if s.trailerAddr != nil {
+		*s.trailerAddr = s.trailer
+	}
+	if s.resp != nil {
+		s.resp.Body.Close()
+	}
+	if err == nil {
+		s.err = io.EOF
+		return io.EOF
+	}
+	s.err = err
+	return err
+}
+
+func (s *webStream) Header() (metadata.MD, error) {
+	return s.header, nil
+}
+
+func (s *webStream) Trailer() metadata.MD {
+	return s.trailer
+}
+
+func (s *webStream) CloseSend() error {
+	return nil
+}
+
+func (s *webStream) Context() context.Context {
+	return s.ctx
+}
+
+func frame(flag byte, b []byte) []byte {
+	f := make([]byte, 5+len(b))
+	f[0] = flag
+	binary.BigEndian.PutUint32(f[1:5], uint32(len(b)))
+	copy(f[5:], b)
+	return f
+}
+
+// readFrame reads a length prefixed frame, io.EOF is returned at the end of
+// the body only.
+func readFrame(r io.Reader, max int) (flag byte, b []byte, err error) {
+	var prefix [5]byte
+	if _, err = io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.EOF {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, status.Errorf(codes.Internal, "failed to read frame: %v", err)
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if int64(n) > int64(max) {
+		return 0, nil, status.Errorf(codes.ResourceExhausted,
+			"received message larger than max (%d vs. %d)", n, max)
+	}
+	b = make([]byte, n)
+	if _, err = io.ReadFull(r, b); err != nil {
+		return 0, nil, status.Errorf(codes.Internal, "failed to read frame: %v", err)
+	}
+	return prefix[0], b, nil
+}
+
+// parseTrailer parses a trailer frame of "key: value" lines.
+func parseTrailer(b []byte) metadata.MD {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(bytes.TrimRight(b, "\r\n"), "\r\n\r\n"...))))
+	h, _ := r.ReadMIMEHeader()
+	return headerMD(http.Header(h))
+}
+
+func headerStatus(h http.Header) *status.Status {
+	if h.Get("Grpc-Status") == "" {
+		return nil
+	}
+	return trailerStatus(headerMD(h))
+}
+
+// trailerStatus returns the status of grpc-status, grpc-message and
+// grpc-status-details-bin metadata.
+func trailerStatus(md metadata.MD) *status.Status {
+	vals := md["grpc-status"]
+	if len(vals) == 0 {
+		return status.New(codes.Internal, "missing grpc-status in trailers")
+	}
+	code, err := strconv.Atoi(vals[0])
+	if err != nil {
+		return status.Newf(codes.Internal, "invalid grpc-status %q", vals[0])
+	}
+	if details := md["grpc-status-details-bin"]; len(details) > 0 {
+		st := new(spb.Status)
+		if err = proto.Unmarshal([]byte(details[0]), st); err == nil {
+			return status.FromProto(st)
+		}
+	}
+	var msg string
+	if vals := md["grpc-message"]; len(vals) > 0 {
+		if msg, err = url.PathUnescape(vals[0]); err != nil {
+			msg = vals[0]
+		}
+	}
+	return status.New(codes.Code(code), msg)
+}
+
+// encodeTimeout formats the grpc-timeout header value.
+func encodeTimeout(d time.Duration) string {
+	if d <= 0 {
+		return "1n"
+	}
+	if ms := d / time.Millisecond; ms < 1e8 {
+		return fmt.Sprintf("%dm", ms+1)
+	}
+	return fmt.Sprintf("%dS", d/time.Second+1)
+}
+
+// base64Reader decodes grpc-web-text bodies. Every frame may be encoded
+// separately with padding, so the body is decoded in groups of four bytes.
+type base64Reader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (r *base64Reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		var quad [4]byte
+		n, err := io.ReadFull(r.r, quad[:])
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid grpc-web-text body length")
+		}
+		out := make([]byte, 3)
+		if n, err = base64.StdEncoding.Decode(out, quad[:]); err != nil {
+			return 0, errors.Wrap(err, "invalid grpc-web-text body")
+		}
+		r.buf = out[:n]
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// webHandler echoes StringValue requests of test.Echo with the gRPC-Web
+// protocol, frames of text responses are encoded separately.
+func webHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		text := strings.HasPrefix(contentType, "application/grpc-web-text")
+		body, _ := ioutil.ReadAll(r.Body)
+		if text {
+			body, _ = base64.StdEncoding.DecodeString(string(body))
+		}
+		flag, b, err := readFrame(bytes.NewReader(body), defaultMaxRecvMsgSize)
+		if err != nil {
+			t.Errorf("failed to read request frame: %v", err)
+		}
+		// Responses are compressed like requests.
+		gz := flag&webCompressed != 0
+		if gz {
+			if r.Header.Get("Grpc-Encoding") != CompressionGzip {
+				t.Errorf("unexpected grpc-encoding %q", r.Header.Get("Grpc-Encoding"))
+			}
+			if b, err = decompress(CompressionGzip, b, defaultMaxRecvMsgSize); err != nil {
+				t.Errorf("failed to decompress request: %v", err)
+			}
+			w.Header().Set("Grpc-Encoding", CompressionGzip)
+		}
+		req := new(wrappers.StringValue)
+		if err = proto.Unmarshal(b, req); err != nil {
+			t.Errorf("failed to unmarshal request: %v", err)
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Id", r.Header.Get("X-Id"))
+		write := func(flag byte, b []byte) {
+			if gz && flag == webDataFrame {
+				flag, b = webCompressed, gzipped(t, b)
+			}
+			f := frame(flag, b)
+			if text {
+				f = []byte(base64.StdEncoding.EncodeToString(f))
+			}
+			w.Write(f)
+		}
+		var n int
+		switch r.URL.Path {
+		case "/test.Echo/Say":
+			n = 1
+		case "/test.Echo/Repeat":
+			n = 3
+		case "/test.Echo/Fail":
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "no%20"+req.Value)
+			return
+		}
+		for i := 0; i < n; i++ {
+			b, _ := proto.Marshal(&wrappers.StringValue{Value: req.Value})
+			write(webDataFrame, b)
+		}
+		write(webTrailerFrame, []byte("grpc-status: 0\r\nx-trailer: done\r\n"))
+	})
+}
+
+// gzipped compresses b with gzip.
+func gzipped(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// checkEncoding checks encodings and sizes of a call with a 100 bytes
+// request and response.
+func checkEncoding(t *testing.T, stats *CallStats, compression string) {
+	want := encodingName(compression == CompressionGzip)
+	if stats.RequestEncoding() != want || stats.ResponseEncoding() != want {
+		t.Errorf("got encodings %s and %s, want %s", stats.RequestEncoding(), stats.ResponseEncoding(), want)
+	}
+	compressed := stats.RequestWireBytes < stats.RequestBytes && stats.ResponseWireBytes < stats.ResponseBytes
+	if stats.RequestBytes == 0 || stats.ResponseBytes == 0 || compressed != (want == CompressionGzip) {
+		t.Errorf("unexpected sizes %+v", stats)
+	}
+}
+
+func TestWebClient(t *testing.T) {
+	srv := httptest.NewServer(webHandler(t))
+	defer srv.Close()
+
+	for _, protocol := range []string{ProtocolGRPCWeb, ProtocolGRPCWebText} {
+		for _, compression := range []string{CompressionNone, CompressionGzip} {
+			t.Run(protocol+"/"+compression, func(t *testing.T) {
+				testWebClient(t, srv.URL, protocol, compression)
+			})
+		}
+	}
+}
+
+func testWebClient(t *testing.T, url, protocol, compression string) {
+	cli, err := NewClient(&ClientCfg{
+		Addr: strings.TrimPrefix(url, "http://"), Protocol: protocol, Compression: compression,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-id", "42")
+
+	var header, trailer metadata.MD
+	resp := new(wrappers.StringValue)
+	stats := new(CallStats)
+	err = cli.Invoke(WithStats(ctx, stats), "test.Echo.Say", &wrappers.StringValue{Value: strings.Repeat("a", 100)}, resp,
+		grpc.Header(&header), grpc.Trailer(&trailer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Value != strings.Repeat("a", 100) {
+		t.Errorf("unexpected response %q", resp.Value)
+	}
+	checkEncoding(t, stats, compression)
+	if got := header.Get("x-id"); len(got) != 1 || got[0] != "42" {
+		t.Errorf("unexpected header x-id %v", got)
+	}
+	if got := trailer.Get("x-trailer"); len(got) != 1 || got[0] != "done" {
+		t.Errorf("unexpected trailer x-trailer %v", got)
+	}
+
+	err = cli.Invoke(ctx, "test.Echo.Fail", &wrappers.StringValue{Value: "luck"}, resp)
+	if st := status.Convert(err); st.Code() != codes.NotFound || st.Message() != "no luck" {
+		t.Errorf("unexpected status %v", err)
+	}
+
+	s, err := cli.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, "test.Echo.Repeat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.SendMsg(&wrappers.StringValue{Value: "again"}); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for {
+		err = s.RecvMsg(resp)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("expected 3 responses, got %d", n)
+	}
+
+	if _, err = cli.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true}, "test.Echo.Say"); status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected Unimplemented for client streaming, got %v", err)
+	}
+}
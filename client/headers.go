@@ -1,3 +1,140 @@
 package client
 
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	binHeaderSuffix = "-bin"
+	reservedPrefix  = "grpc-"
+)
+
+// Headers is outgoing metadata, a key may have several values. Values of
+// binary keys ending with "-bin" hold raw bytes.
 type Headers map[string][]string
+
+// ValidateKey checks the metadata key syntax: lowercase letters, digits,
+// "-", "_" and ".", without the reserved "grpc-" prefix.
+func ValidateKey(key string) error {
+	if key == "" {
+		return errors.New("metadata key is empty")
+	}
+	if strings.HasPrefix(key, reservedPrefix) {
+		return errors.Errorf("metadata key \"%s\" uses the reserved grpc- prefix", key)
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return errors.Errorf("metadata key \"%s\" contains invalid character %q", key, r)
+		}
+	}
+	return nil
+}
+
+// ParseValue validates the value of the key, values of binary keys are
+// given base64 encoded and decoded to raw bytes.
+func ParseValue(key, value string) (string, error) {
+	if IsBinary(key) {
+		for _, enc := range []*base64.Encoding{
+			base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+		} {
+			if b, err := enc.DecodeString(value); err == nil {
+				return string(b), nil
+			}
+		}
+		return "", errors.Errorf("value of binary key \"%s\" must be base64 encoded", key)
+	}
+	for _, r := range value {
+		if r < 0x20 || r > 0x7E {
+			return "", errors.Errorf("value of key \"%s\" contains non-printable ASCII character %q", key, r)
+		}
+	}
+	return value, nil
+}
+
+// IsBinary reports whether the key holds binary values.
+func IsBinary(key string) bool {
+	return strings.HasSuffix(key, binHeaderSuffix)
+}
+
+func normalizeKey(key string) (string, error) {
+	key = strings.ToLower(key)
+	return key, ValidateKey(key)
+}
+
+// Add appends the value to the key, see ParseValue.
+func (h Headers) Add(key, value string) error {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+	if value, err = ParseValue(key, value); err != nil {
+		return err
+	}
+	h[key] = append(h[key], value)
+	return nil
+}
+
+// Set replaces values of the key, see ParseValue.
+func (h Headers) Set(key, value string) error {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+	if value, err = ParseValue(key, value); err != nil {
+		return err
+	}
+	h[key] = []string{value}
+	return nil
+}
+
+// Del removes the given values of the key, or all of them if none given.
+func (h Headers) Del(key string, values ...string) {
+	key = strings.ToLower(key)
+	if len(values) == 0 {
+		delete(h, key)
+		return
+	}
+	kept := h[key][:0]
+	for _, v := range h[key] {
+		if !contains(values, v) && !(IsBinary(key) && contains(values, base64.StdEncoding.EncodeToString([]byte(v)))) {
+			kept = append(kept, v)
+		}
+	}
+	if len(kept) == 0 {
+		delete(h, key)
+		return
+	}
+	h[key] = kept
+}
+
+// Printable returns a copy with base64 encoded binary values.
+func (h Headers) Printable() map[string][]string {
+	p := make(map[string][]string, len(h))
+	for k, vals := range h {
+		p[k] = append([]string(nil), vals...)
+		if IsBinary(k) {
+			for i, v := range vals {
+				p[k][i] = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+		}
+	}
+	return p
+}
+
+// MD returns a copy of the headers as metadata.
+func (h Headers) MD() metadata.MD {
+	return metadata.MD(h).Copy()
+}
+
+func contains(values []string, v string) bool {
+	for _, val := range values {
+		if val == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeaders(t *testing.T) {
+	h := Headers{}
+	for _, kv := range [][2]string{{"X-Id", "1"}, {"x-id", "2"}, {"trace-bin", "AAEC"}} {
+		if err := h.Add(kv[0], kv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(h["x-id"], []string{"1", "2"}) {
+		t.Errorf("x-id = %v", h["x-id"])
+	}
+	if h["trace-bin"][0] != "\x00\x01\x02" {
+		t.Errorf("trace-bin = %q", h["trace-bin"][0])
+	}
+	if p := h.Printable(); p["trace-bin"][0] != "AAEC" {
+		t.Errorf("printable trace-bin = %q", p["trace-bin"][0])
+	}
+
+	h.Del("x-id", "1")
+	if !reflect.DeepEqual(h["x-id"], []string{"2"}) {
+		t.Errorf("x-id after delete = %v", h["x-id"])
+	}
+	h.Del("trace-bin", "AAEC")
+	if _, ok := h["trace-bin"]; ok {
+		t.Error("trace-bin not deleted")
+	}
+
+	for _, kv := range [][2]string{{"grpc-timeout", "1"}, {"x id", "1"}, {"", "1"}, {"x-bin", "!!"}, {"x", "\n"}} {
+		if err := h.Add(kv[0], kv[1]); err == nil {
+			t.Errorf("Add(%q, %q) succeeded", kv[0], kv[1])
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Protocols of ClientCfg.Protocol, gRPC is used by default.
+const (
+	ProtocolGRPC        = "grpc"
+	ProtocolGRPCWeb     = "grpc-web"
+	ProtocolGRPCWebText = "grpc-web-text"
+	ProtocolConnect     = "connect"
+	ProtocolConnectJSON = "connect-json"
+)
+
+// Protocols lists the supported protocols.
+var Protocols = []string{ProtocolGRPC, ProtocolGRPCWeb, ProtocolGRPCWebText, ProtocolConnect, ProtocolConnectJSON}
+
+// CheckProtocol returns an error if the protocol is not supported, empty
+// means gRPC.
+func CheckProtocol(protocol string) error {
+	if protocol == "" || contains(Protocols, protocol) {
+		return nil
+	}
+	return errors.Errorf("unsupported protocol %s, expected one of %s", protocol, strings.Join(Protocols, ", "))
+}
+
+// defaultMaxRecvMsgSize is the gRPC default limit of received messages.
+const defaultMaxRecvMsgSize = 4 * 1024 * 1024
+
+// httpTransport sends calls of HTTP based protocols.
+type httpTransport struct {
+	client     *http.Client
+	baseURL    string
+	authority  string
+	userAgent  string
+	creds      credentials.PerRPCCredentials
+	maxRecvMsg int
+	// gzip compresses requests.
+	gzip bool
+}
+
+func newHTTPTransport(cfg *ClientCfg) (*httpTransport, error) {
+	gzip, err := compressed(cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+	t := &httpTransport{
+		gzip:       gzip,
+		creds:      cfg.Credentials,
+		authority:  cfg.Dial.Authority,
+		userAgent:  cfg.Dial.UserAgent,
+		maxRecvMsg: cfg.Dial.MaxRecvMsgSize,
+	}
+	if t.maxRecvMsg <= 0 {
+		t.maxRecvMsg = defaultMaxRecvMsgSize
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout(cfg.Dial)}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment, DialContext: dialer.DialContext}
+	host := strings.TrimPrefix(cfg.Addr, "dns:///")
+	if sock, ok := UnixSocket(cfg.Addr); ok {
+		host = "localhost"
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", sock)
+		}
+	} else if strings.Contains(host, ",") || strings.Contains(host, ":///") {
+		return nil, errors.Errorf("target %s is not supported by HTTP based protocols", cfg.Addr)
+	}
+
+	scheme := "http"
+	if cfg.WithTLS {
+		scheme = "https"
+		tlsCfg := &tls.Config{ServerName: cfg.ServerName}
+		if cfg.Certs.HasCaCert() {
+			tlsCfg.RootCAs = cfg.Certs.CACert()
+		}
+		if cfg.Certs.HasCert() {
+			tlsCfg.Certificates = append(tlsCfg.Certificates, cfg.Certs.Cert())
+		}
+		if tlsCfg.ServerName == "" && t.authority != "" {
+			tlsCfg.ServerName = t.authority
+			if h, _, err := net.SplitHostPort(t.authority); err == nil {
+				tlsCfg.ServerName = h
+			}
+		}
+		transport.TLSClientConfig = tlsCfg
+	}
+	t.client = &http.Client{Transport: transport}
+	t.baseURL = fmt.Sprintf("%s://%s", scheme, host)
+	return t, nil
+}
+
+// newRequest returns a POST request of the method with outgoing metadata of
+// the context and per-RPC credentials as headers.
+func (t *httpTransport) newRequest(ctx context.Context, method, contentType string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, t.baseURL+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create HTTP request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	if t.authority != "" {
+		req.Host = t.authority
+	}
+	if t.userAgent != "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for k, vals := range md {
+		for _, v := range vals {
+			if IsBinary(k) {
+				v = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			req.Header.Add(k, v)
+		}
+	}
+	if t.creds != nil {
+		auth, err := t.creds.GetRequestMetadata(ctx, t.baseURL+method)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get per-RPC credentials")
+		}
+		for k, v := range auth {
+			req.Header.Set(k, v)
+		}
+	}
+	return req, nil
+}
+
+// compress returns the message compressed with gzip if the transport
+// compresses requests.
+func (t *httpTransport) compress(b []byte) ([]byte, error) {
+	if !t.gzip {
+		return b, nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, errors.Wrap(err, "failed to compress request")
+	}
+	if err := w.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to compress request")
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress decodes a message of the encoding, up to max bytes.
+func decompress(encoding string, b []byte, max int) ([]byte, error) {
+	switch encoding {
+	case "", "identity":
+		return b, nil
+	case CompressionGzip:
+	default:
+		return nil, status.Errorf(codes.Internal, "unsupported response encoding %s", encoding)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decompress response: %v", err)
+	}
+	out, err := ioutil.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decompress response: %v", err)
+	}
+	if len(out) > max {
+		return nil, status.Errorf(codes.ResourceExhausted, "received message larger than max (%d)", max)
+	}
+	return out, nil
+}
+
+func (t *httpTransport) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
+
+// headerMD converts HTTP headers to metadata, values of binary keys are
+// decoded and transport headers are skipped.
+func headerMD(h http.Header, skip ...string) metadata.MD {
+	md := metadata.MD{}
+	for k, vals := range h {
+		k = strings.ToLower(k)
+		if contains(skip, k) {
+			continue
+		}
+		for _, v := range vals {
+			if IsBinary(k) {
+				if b, err := base64.StdEncoding.DecodeString(v); err == nil {
+					v = string(b)
+				} else if b, err = base64.RawStdEncoding.DecodeString(v); err == nil {
+					v = string(b)
+				}
+			}
+			md[k] = append(md[k], v)
+		}
+	}
+	return md
+}
+
+// transportError converts errors of HTTP requests to statuses.
+func transportError(ctx context.Context, err error) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+// httpStatusCode maps HTTP statuses of responses without a gRPC status, as
+// gRPC clients do.
+func httpStatusCode(status int) codes.Code {
+	switch status {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	}
+	return codes.Unknown
+}
+
+// callOptions returns where headers and trailers of the call are stored.
+func callOptions(opts []grpc.CallOption) (header, trailer *metadata.MD) {
+	for _, o := range opts {
+		switch o := o.(type) {
+		case grpc.HeaderCallOption:
+			header = o.HeaderAddr
+		case grpc.TrailerCallOption:
+			trailer = o.TrailerAddr
+		}
+	}
+	return
+}
+
+func marshalMessage(m interface{}) ([]byte, error) {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil, errors.Errorf("%T is not a proto message", m)
+	}
+	return proto.Marshal(msg)
+}
+
+func unmarshalMessage(b []byte, m interface{}) error {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return errors.Errorf("%T is not a proto message", m)
+	}
+	return proto.Unmarshal(b, msg)
+}
+
+// methodPath returns the "/pkg.Service/Method" path of the fully qualified
+// name.
+func methodPath(fqrn string) (string, error) {
+	method, err := fullQualifiedRPCNameToMethod(fqrn)
+	if err != nil {
+		return "", err
+	}
+	return (&url.URL{Path: method}).EscapedPath(), nil
+}
@@ -0,0 +1,327 @@
+package client
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/config"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// retryJitter randomizes backoff delays by up to 20% either way.
+const retryJitter = 0.2
+
+// Attempt is a failed attempt of a retried call.
+type Attempt struct {
+	Err      error
+	Duration time.Duration
+	// Backoff is the delay before the next attempt.
+	Backoff time.Duration
+}
+
+// addRetry records the failed attempt in stats of the call.
+func addRetry(ctx context.Context, a Attempt) {
+	s, ok := ctx.Value(statsKey{}).(*CallStats)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Retries = append(s.Retries, a)
+}
+
+// addHedge counts an attempt of a hedged call in stats of the call.
+func addHedge(ctx context.Context) {
+	s, ok := ctx.Value(statsKey{}).(*CallStats)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hedged++
+}
+
+// retryClient retries unary and server streaming calls failing with
+// retryable codes, or hedges unary calls, other streams are called once.
+type retryClient struct {
+	Client
+	policy config.Retry
+	codes  map[codes.Code]bool
+}
+
+// withRetry wraps the client if the policy retries calls.
+func withRetry(cli Client, policy config.Retry) (Client, error) {
+	if !policy.Enabled() {
+		return cli, nil
+	}
+	retryable, err := policy.RetryableCodes()
+	if err != nil {
+		return nil, err
+	}
+	c := &retryClient{Client: cli, policy: policy, codes: make(map[codes.Code]bool)}
+	for _, code := range retryable {
+		c.codes[code] = true
+	}
+	return c, nil
+}
+
+// backoff records the failed attempt and waits before the next one, it
+// returns false if the call must not be retried.
+func (c *retryClient) backoff(ctx context.Context, attempt int, start time.Time, err error) bool {
+	a := Attempt{Err: err, Duration: time.Since(start)}
+	if attempt >= c.policy.MaxAttempts || !c.codes[status.Code(err)] || ctx.Err() != nil {
+		return false
+	}
+	jitter := 1 + retryJitter*(2*rand.Float64()-1)
+	a.Backoff = time.Duration(float64(c.policy.Backoff(attempt)) * jitter)
+	addRetry(ctx, a)
+
+	timer := time.NewTimer(a.Backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *retryClient) Invoke(ctx context.Context, fqrn string, req, resp interface{}, opts ...grpc.CallOption) error {
+	if c.policy.HedgingDelay > 0 {
+		return c.hedge(ctx, fqrn, req, resp, opts...)
+	}
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := c.Client.Invoke(ctx, fqrn, req, resp, opts...)
+		if err == nil || !c.backoff(ctx, attempt, start, err) {
+			return err
+		}
+		// A failed attempt may have decoded a part of the response.
+		if m, ok := resp.(proto.Message); ok {
+			m.Reset()
+		}
+	}
+}
+
+// hedged is an attempt of a hedged call.
+type hedged struct {
+	resp    proto.Message
+	header  metadata.MD
+	trailer metadata.MD
+	start   time.Time
+	err     error
+}
+
+// hedge sends attempts of the call in parallel, a new one after the hedging
+// delay or a failure with a retryable code, until one succeeds. The others
+// are cancelled then.
+func (c *retryClient) hedge(ctx context.Context, fqrn string, req, resp interface{}, opts ...grpc.CallOption) error {
+	m, ok := resp.(proto.Message)
+	if !ok {
+		return errors.Errorf("hedged response %T is not a message", resp)
+	}
+	var headerAddr, trailerAddr *metadata.MD
+	var attemptOpts []grpc.CallOption
+	for _, o := range opts {
+		switch o := o.(type) {
+		case grpc.HeaderCallOption:
+			headerAddr = o.HeaderAddr
+		case grpc.TrailerCallOption:
+			trailerAddr = o.TrailerAddr
+		default:
+			attemptOpts = append(attemptOpts, o)
+		}
+	}
+
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan *hedged, c.policy.MaxAttempts)
+	sent := 0
+	send := func() <-chan time.Time {
+		sent++
+		addHedge(ctx)
+		a := &hedged{resp: newMessage(m), start: time.Now()}
+		go func() {
+			opts := append([]grpc.CallOption{grpc.Header(&a.header), grpc.Trailer(&a.trailer)}, attemptOpts...)
+			a.err = c.Client.Invoke(attemptCtx, fqrn, req, a.resp, opts...)
+			done <- a
+		}()
+		if sent == c.policy.MaxAttempts {
+			return nil
+		}
+		return time.After(time.Duration(c.policy.HedgingDelay))
+	}
+
+	next, running := send(), 1
+	for {
+		select {
+		case <-next:
+			next = send()
+			running++
+		case a := <-done:
+			running--
+			if a.err == nil {
+				m.Reset()
+				proto.Merge(m, a.resp)
+				if headerAddr != nil {
+					*headerAddr = a.header
+				}
+				if trailerAddr != nil {
+					*trailerAddr = a.trailer
+				}
+				return nil
+			}
+			if !c.codes[status.Code(a.err)] || ctx.Err() != nil || (running == 0 && next == nil) {
+				return a.err
+			}
+			addRetry(ctx, Attempt{Err: a.err, Duration: time.Since(a.start)})
+			if next != nil {
+				next = send()
+				running++
+			}
+		}
+	}
+}
+
+// newMessage returns an empty message of the type of m.
+func newMessage(m proto.Message) proto.Message {
+	if dm, ok := m.(*dynamic.Message); ok {
+		return dynamic.NewMessage(dm.GetMessageDescriptor())
+	}
+	return reflect.New(reflect.TypeOf(m).Elem()).Interface().(proto.Message)
+}
+
+func (c *retryClient) NewStream(
+	ctx context.Context, desc *grpc.StreamDesc, fqrn string, opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	if desc.ClientStreams || !desc.ServerStreams {
+		return c.Client.NewStream(ctx, desc, fqrn, opts...)
+	}
+	s := &retryStream{c: c, ctx: ctx, desc: desc, fqrn: fqrn, opts: opts}
+	if err := s.retry(nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// retryStream reopens a server stream until its first response is
+// received, the request is sent again to each attempt.
+type retryStream struct {
+	c    *retryClient
+	ctx  context.Context
+	desc *grpc.StreamDesc
+	fqrn string
+	opts []grpc.CallOption
+
+	// mu guards the attempt against sends of another goroutine.
+	mu       sync.Mutex
+	cs       grpc.ClientStream
+	cancel   context.CancelFunc
+	attempt  int
+	start    time.Time
+	req      interface{}
+	closed   bool
+	received bool
+}
+
+// retry opens streams until one is opened, err is the failure of the
+// current attempt, it is nil for the first one.
+func (s *retryStream) retry(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		if err != nil && !s.c.backoff(s.ctx, s.attempt, s.start, err) {
+			return err
+		}
+		s.attempt++
+		s.start = time.Now()
+		if err = s.open(); err == nil {
+			return nil
+		}
+	}
+}
+
+// open starts the attempt and replays the request sent so far.
+func (s *retryStream) open() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(s.ctx)
+	cs, err := s.c.Client.NewStream(ctx, s.desc, s.fqrn, s.opts...)
+	if err != nil {
+		return err
+	}
+	s.cs = cs
+	// Failed sends are reported by RecvMsg.
+	if s.req != nil {
+		if err = cs.SendMsg(s.req); err != nil && err != io.EOF {
+			return err
+		}
+	}
+	if s.closed {
+		return cs.CloseSend()
+	}
+	return nil
+}
+
+func (s *retryStream) current() grpc.ClientStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cs
+}
+
+func (s *retryStream) SendMsg(m interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.req = m
+	return s.cs.SendMsg(m)
+}
+
+func (s *retryStream) CloseSend() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return s.cs.CloseSend()
+}
+
+func (s *retryStream) RecvMsg(m interface{}) error {
+	for {
+		err := s.current().RecvMsg(m)
+		if err == nil {
+			s.received = true
+			return nil
+		}
+		// Responses are not replayed, so the stream fails once one is
+		// received.
+		if err == io.EOF || s.received {
+			return err
+		}
+		if err = s.retry(err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *retryStream) Header() (metadata.MD, error) {
+	return s.current().Header()
+}
+
+func (s *retryStream) Trailer() metadata.MD {
+	return s.current().Trailer()
+}
+
+func (s *retryStream) Context() context.Context {
+	return s.current().Context()
+}
@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/config"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// flakyClient fails the given number of calls before answering them with
+// the request, streams fail after sending the given number of responses.
+type flakyClient struct {
+	Client
+	failures int
+	code     codes.Code
+	calls    int
+	sent     int
+}
+
+func (c *flakyClient) Invoke(_ context.Context, _ string, req, resp interface{}, _ ...grpc.CallOption) error {
+	c.calls++
+	if c.calls <= c.failures {
+		return status.Error(c.code, "flaky")
+	}
+	proto.Merge(resp.(proto.Message), req.(proto.Message))
+	return nil
+}
+
+func (c *flakyClient) NewStream(context.Context, *grpc.StreamDesc, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+	c.calls++
+	return &flakyStream{c: c, fail: c.calls <= c.failures}, nil
+}
+
+type flakyStream struct {
+	grpc.ClientStream
+	c    *flakyClient
+	fail bool
+	req  proto.Message
+	sent int
+}
+
+func (s *flakyStream) SendMsg(m interface{}) error {
+	s.req = m.(proto.Message)
+	return nil
+}
+
+func (s *flakyStream) CloseSend() error {
+	return nil
+}
+
+func (s *flakyStream) RecvMsg(m interface{}) error {
+	if s.fail && s.sent == s.c.sent {
+		return status.Error(s.c.code, "flaky")
+	}
+	if s.sent == 2 {
+		return io.EOF
+	}
+	s.sent++
+	proto.Merge(m.(proto.Message), s.req)
+	return nil
+}
+
+func TestRetry(t *testing.T) {
+	policy := config.Retry{MaxAttempts: 3, InitialBackoff: config.Duration(time.Millisecond), Codes: []string{"unavailable"}}
+	tests := []struct {
+		name     string
+		failures int
+		code     codes.Code
+		attempts int
+		err      codes.Code
+	}{
+		{name: "success", attempts: 1},
+		{name: "retried", failures: 2, code: codes.Unavailable, attempts: 3},
+		{name: "exhausted", failures: 3, code: codes.Unavailable, attempts: 3, err: codes.Unavailable},
+		{name: "not retryable", failures: 1, code: codes.NotFound, attempts: 1, err: codes.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaky := &flakyClient{failures: tt.failures, code: tt.code}
+			cli, err := withRetry(flaky, policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats := new(CallStats)
+			resp := new(wrappers.StringValue)
+			err = cli.Invoke(WithStats(context.Background(), stats), "test.Echo.Say", &wrappers.StringValue{Value: "hi"}, resp)
+			if status.Code(err) != tt.err {
+				t.Fatalf("expected %s, got %v", tt.err, err)
+			}
+			if flaky.calls != tt.attempts || stats.Attempts() != tt.attempts {
+				t.Errorf("expected %d attempts, got %d calls and %d in stats", tt.attempts, flaky.calls, stats.Attempts())
+			}
+			if err == nil && resp.Value != "hi" {
+				t.Errorf("unexpected response %q", resp.Value)
+			}
+		})
+	}
+
+	t.Run("stream", func(t *testing.T) {
+		flaky := &flakyClient{failures: 1, code: codes.Unavailable}
+		cli, err := withRetry(flaky, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := cli.NewStream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, "test.Echo.Repeat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SendMsg(&wrappers.StringValue{Value: "hi"})
+		s.CloseSend()
+		var n int
+		for {
+			resp := new(wrappers.StringValue)
+			if err = s.RecvMsg(resp); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Value != "hi" {
+				t.Errorf("the request is not replayed, got %q", resp.Value)
+			}
+			n++
+		}
+		if n != 2 || flaky.calls != 2 {
+			t.Errorf("expected 2 responses of the second attempt, got %d of %d attempts", n, flaky.calls)
+		}
+	})
+
+	t.Run("stream after responses", func(t *testing.T) {
+		flaky := &flakyClient{failures: 1, code: codes.Unavailable, sent: 1}
+		cli, err := withRetry(flaky, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := cli.NewStream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, "test.Echo.Repeat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SendMsg(&wrappers.StringValue{Value: "hi"})
+		s.CloseSend()
+		resp := new(wrappers.StringValue)
+		if err = s.RecvMsg(resp); err != nil {
+			t.Fatal(err)
+		}
+		if err = s.RecvMsg(resp); status.Code(err) != codes.Unavailable || flaky.calls != 1 {
+			t.Errorf("expected Unavailable without retries, got %v after %d attempts", err, flaky.calls)
+		}
+	})
+
+	if _, err := withRetry(&flakyClient{}, config.Retry{MaxAttempts: 2, Codes: []string{"FLAKY"}}); err == nil {
+		t.Error("unknown code accepted")
+	}
+}
+
+// hedgedClient answers call i after delays[i] with codes[i], it is called
+// concurrently by hedged calls.
+type hedgedClient struct {
+	Client
+	delays []time.Duration
+	codes  []codes.Code
+	mu     sync.Mutex
+	calls  int
+}
+
+func (c *hedgedClient) Invoke(ctx context.Context, _ string, req, resp interface{}, opts ...grpc.CallOption) error {
+	c.mu.Lock()
+	i := c.calls
+	c.calls++
+	c.mu.Unlock()
+	select {
+	case <-time.After(c.delays[i]):
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if c.codes[i] != codes.OK {
+		return status.Error(c.codes[i], "flaky")
+	}
+	for _, o := range opts {
+		if h, ok := o.(grpc.HeaderCallOption); ok {
+			*h.HeaderAddr = metadata.Pairs("attempt", strconv.Itoa(i+1))
+		}
+	}
+	proto.Merge(resp.(proto.Message), req.(proto.Message))
+	return nil
+}
+
+func TestHedging(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		delays   []time.Duration
+		codes    []codes.Code
+		attempts int
+		err      codes.Code
+	}{
+		{
+			name:  "slow first attempt",
+			delay: 50 * time.Millisecond, delays: []time.Duration{time.Hour, 0, time.Hour},
+			codes:    []codes.Code{codes.OK, codes.OK, codes.OK},
+			attempts: 2,
+		},
+		{
+			name:  "failure sends next attempt",
+			delay: time.Hour, delays: []time.Duration{0, 0, 0},
+			codes:    []codes.Code{codes.Unavailable, codes.OK, codes.OK},
+			attempts: 2,
+		},
+		{
+			name:  "not retryable",
+			delay: time.Hour, delays: []time.Duration{0, 0, 0},
+			codes:    []codes.Code{codes.NotFound, codes.OK, codes.OK},
+			attempts: 1, err: codes.NotFound,
+		},
+		{
+			name:  "exhausted",
+			delay: time.Millisecond, delays: []time.Duration{0, 0, 0},
+			codes:    []codes.Code{codes.Unavailable, codes.Unavailable, codes.Unavailable},
+			attempts: 3, err: codes.Unavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hedged := &hedgedClient{delays: tt.delays, codes: tt.codes}
+			cli, err := withRetry(hedged, config.Retry{MaxAttempts: 3, HedgingDelay: config.Duration(tt.delay)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats := new(CallStats)
+			resp := new(wrappers.StringValue)
+			var header metadata.MD
+			err = cli.Invoke(WithStats(context.Background(), stats), "test.Echo.Say",
+				&wrappers.StringValue{Value: "hi"}, resp, grpc.Header(&header))
+			if status.Code(err) != tt.err {
+				t.Fatalf("expected %s, got %v", tt.err, err)
+			}
+			if stats.Attempts() != tt.attempts {
+				t.Errorf("expected %d attempts, got %d", tt.attempts, stats.Attempts())
+			}
+			if err != nil {
+				return
+			}
+			if got := header.Get("attempt"); resp.Value != "hi" || len(got) != 1 || got[0] != strconv.Itoa(tt.attempts) {
+				t.Errorf("expected the response of attempt %d, got %q with header %v", tt.attempts, resp.Value, header)
+			}
+		})
+	}
+}
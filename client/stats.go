@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/stats"
+)
+
+// Compression names accepted by ClientCfg.
+const (
+	CompressionNone = "none"
+	CompressionGzip = gzip.Name
+)
+
+// compressed reports whether requests are compressed with gzip.
+func compressed(compression string) (bool, error) {
+	switch compression {
+	case "", CompressionNone:
+		return false, nil
+	case CompressionGzip:
+		return true, nil
+	}
+	return false, errors.Errorf("unsupported compression %s, expected %s or %s",
+		compression, CompressionGzip, CompressionNone)
+}
+
+// msgPrefixLen is the length of the gRPC message prefix counted in wire
+// lengths of outgoing payloads.
+const msgPrefixLen = 5
+
+// CallStats are message sizes of a call, wire sizes are the compressed ones.
+type CallStats struct {
+	mu                sync.Mutex
+	RequestBytes      int
+	RequestWireBytes  int
+	ResponseBytes     int
+	ResponseWireBytes int
+	// Retries are failed attempts of a retried call.
+	Retries            []Attempt
+	requestCompressed  bool
+	responseCompressed bool
+	// hedged counts attempts of a hedged call, failed or not.
+	hedged int
+}
+
+// Attempts returns the number of attempts of the call.
+func (s *CallStats) Attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hedged > 0 {
+		return s.hedged
+	}
+	return len(s.Retries) + 1
+}
+
+// RequestEncoding returns the encoding requests were sent with.
+func (s *CallStats) RequestEncoding() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return encodingName(s.requestCompressed)
+}
+
+// ResponseEncoding returns the encoding of responses. The client advertises
+// gzip only, so a response compressed by the server is gzip encoded.
+func (s *CallStats) ResponseEncoding() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return encodingName(s.responseCompressed)
+}
+
+func encodingName(compressed bool) string {
+	if compressed {
+		return gzip.Name
+	}
+	return encoding.Identity
+}
+
+type statsKey struct{}
+
+// WithStats returns a context collecting stats of calls made with it.
+func WithStats(ctx context.Context, s *CallStats) context.Context {
+	return context.WithValue(ctx, statsKey{}, s)
+}
+
+// addStats counts message sizes of calls made without gRPC stats handlers,
+// wire sizes are the compressed ones.
+func addStats(ctx context.Context, out, outWire, in, inWire int) {
+	s, ok := ctx.Value(statsKey{}).(*CallStats)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.RequestBytes += out
+	s.RequestWireBytes += outWire
+	s.ResponseBytes += in
+	s.ResponseWireBytes += inWire
+	if outWire != out {
+		s.requestCompressed = true
+	}
+	if inWire != in {
+		s.responseCompressed = true
+	}
+}
+
+type statsHandler struct{}
+
+func (statsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (statsHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	s, ok := ctx.Value(statsKey{}).(*CallStats)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch rs := rs.(type) {
+	case *stats.OutPayload:
+		s.RequestBytes += rs.Length
+		s.RequestWireBytes += rs.WireLength - msgPrefixLen
+		if rs.WireLength-msgPrefixLen != rs.Length {
+			s.requestCompressed = true
+		}
+	case *stats.InPayload:
+		s.ResponseBytes += rs.Length
+		s.ResponseWireBytes += rs.WireLength
+		if rs.WireLength != rs.Length {
+			s.responseCompressed = true
+		}
+	}
+}
+
+func (statsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (statsHandler) HandleConn(context.Context, stats.ConnStats) {}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/roundrobin"
+	"google.golang.org/grpc/resolver"
+)
+
+// Load balancing policies of targets resolving to several addresses.
+const (
+	BalancerPickFirst  = "pick_first"
+	BalancerRoundRobin = roundrobin.Name
+)
+
+const (
+	unixPrefix         = "unix:"
+	unixAbstractPrefix = "unix-abstract:"
+	staticScheme       = "static"
+)
+
+func init() {
+	resolver.Register(staticBuilder{})
+}
+
+// UnixSocket returns the socket address of "unix:///path", "unix:path" and
+// "unix-abstract:name" targets, ok is false for other targets.
+func UnixSocket(target string) (addr string, ok bool) {
+	switch {
+	case strings.HasPrefix(target, unixAbstractPrefix):
+		// Abstract socket names start with a NUL byte, written as "@".
+		return "@" + strings.TrimPrefix(target, unixAbstractPrefix), true
+	case strings.HasPrefix(target, unixPrefix+"//"):
+		return strings.TrimPrefix(target, unixPrefix+"//"), true
+	case strings.HasPrefix(target, unixPrefix):
+		return strings.TrimPrefix(target, unixPrefix), true
+	}
+	return "", false
+}
+
+// dialTarget converts the target to a gRPC target name with dial options.
+// Besides host:port and resolver schemes like "dns:///" it accepts unix
+// sockets and comma separated address lists, the authority of a list is its
+// first address.
+func dialTarget(target, balancer string) (name, authority string, opts []grpc.DialOption, err error) {
+	switch balancer {
+	case "", BalancerPickFirst:
+	case BalancerRoundRobin:
+		opts = append(opts, grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingPolicy": "%s"}`, balancer)))
+	default:
+		return "", "", nil, errors.Errorf("unsupported balancer %s, expected %s or %s",
+			balancer, BalancerPickFirst, BalancerRoundRobin)
+	}
+
+	if addr, ok := UnixSocket(target); ok {
+		if addr == "" || addr == "@" {
+			return "", "", nil, errors.Errorf("invalid target %s: socket path is empty", target)
+		}
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", addr)
+		}))
+		// The endpoint is used as the authority only, the dialer ignores it.
+		return "passthrough:///localhost", "", opts, nil
+	}
+	if strings.Contains(target, ",") {
+		addrs := strings.Split(target, ",")
+		for _, addr := range addrs {
+			if _, _, err = net.SplitHostPort(strings.TrimSpace(addr)); err != nil {
+				return "", "", nil, errors.Wrapf(err, "invalid address %s of target", addr)
+			}
+		}
+		authority = strings.TrimSpace(addrs[0])
+		return staticScheme + ":///" + target, authority, append(opts, grpc.WithAuthority(authority)), nil
+	}
+	return target, "", opts, nil
+}
+
+// staticBuilder resolves "static:///host1:port,host2:port" targets to the
+// listed addresses.
+type staticBuilder struct{}
+
+func (staticBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOption) (resolver.Resolver, error) {
+	var addrs []resolver.Address
+	for _, addr := range strings.Split(target.Endpoint, ",") {
+		addrs = append(addrs, resolver.Address{Addr: strings.TrimSpace(addr)})
+	}
+	cc.UpdateState(resolver.State{Addresses: addrs})
+	return staticResolver{}, nil
+}
+
+func (staticBuilder) Scheme() string {
+	return staticScheme
+}
+
+type staticResolver struct{}
+
+func (staticResolver) ResolveNow(resolver.ResolveNowOption) {}
+
+func (staticResolver) Close() {}
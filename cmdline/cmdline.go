@@ -0,0 +1,27 @@
+// Package cmdline handles arguments of shell lines split on spaces.
+package cmdline
+
+import "strings"
+
+// Delimiter separates arguments of shell lines.
+const Delimiter = " "
+
+// JoinQuoted joins a value quoted across the spaces the line is split on,
+// e.g. "authorization: Bearer tok". It returns the value without quotes and
+// the number of the rest of arguments joined to it; an unterminated value
+// is returned with its quote.
+func JoinQuoted(value string, rest []string) (string, int) {
+	if value == "" || value[0] != '\'' && value[0] != '"' {
+		return value, 0
+	}
+	quote := value[:1]
+	n := 0
+	for len(value) < 2 || !strings.HasSuffix(value, quote) {
+		if n == len(rest) {
+			return value, n
+		}
+		value += Delimiter + rest[n]
+		n++
+	}
+	return value[1 : len(value)-1], n
+}
@@ -1,45 +1,211 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 )
 
+// Commands selected by the first positional argument, the interactive
+// shell is started when none is given.
+const (
+	CommandServe   = "serve"
+	CommandBench   = "bench"
+	CommandBatch   = "batch"
+	CommandHealth  = "health"
+	CommandGateway = "gateway"
+	CommandProxy   = "proxy"
+)
+
+// Output formats of one-shot commands.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
 type Config struct {
 	Default  *Default
 	Server   *Server
 	Input    *Input
+	Mock     *Mock
+	Proxy    *Proxy
+	Bench    *Bench
+	Batch    *Batch
+	Health   *Health
+	Diff     *Diff
+	Profiles map[string]*Profile
+	// Groups are target groups of fan-out calls, members are profile
+	// names, patterns of them or addresses.
+	Groups map[string][]string
+	File   string
+	JWKS   string
+	// Redact lists patterns of headers masked in output, history and
+	// recordings, the defaults are used if it is empty.
+	Redact  []string
+	History string
+	// Verbose shows headers, trailers and message sizes of calls.
+	Verbose bool
+	// Listen is the address of the gateway and proxy commands.
+	Listen string
+	// Faults is the file with faults injected by the serve and proxy
+	// commands.
+	Faults   string
 	Describe string
+	Command  string
+	Args     []string
 	help     bool
 }
 
 type Default struct {
 	ProtoPath []string
 	ProtoFile []string
+	Protoset  []string
 	Package   string
 	Service   string
 	Method    string
 }
 
 type Server struct {
-	Host       string
-	Port       string
-	Reflection bool
-	TLS        bool
-	CACert     string
-	Cert       string
-	CertKey    string
-	Name       string
+	Host       string `json:"host"`
+	Port       string `json:"port"`
+	Reflection bool   `json:"reflection"`
+	TLS        bool   `json:"tls"`
+	CACert     string `json:"cacert"`
+	Cert       string `json:"cert"`
+	CertKey    string `json:"certkey"`
+	Name       string `json:"servername"`
+	Auth       Auth   `json:"auth"`
+	// Compression of requests, "gzip" or "none".
+	Compression string `json:"compression"`
+	// Target is used instead of host and port: "unix:///path/to.sock",
+	// "unix-abstract:name", "dns:///host:port" or "host1:port,host2:port".
+	Target string `json:"target"`
+	// Balancer is "pick_first" or "round_robin".
+	Balancer string `json:"balancer"`
+	Dial     Dial   `json:"dial"`
+	// Protocol is "grpc", "grpc-web", "grpc-web-text", "connect" or
+	// "connect-json".
+	Protocol string `json:"protocol"`
+	Retry    Retry  `json:"retry"`
+}
+
+// Auth configures a credential provider, at most one of the token file,
+// the OAuth2 token URL and the exec command is used.
+type Auth struct {
+	TokenFile    string   `json:"token_file"`
+	TokenURL     string   `json:"token_url"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+	Exec         string   `json:"exec"`
+}
+
+// Bench holds settings of the bench command.
+type Bench struct {
+	Concurrency int
+	Requests    int
+	Duration    time.Duration
+	RPS         int
+}
+
+// Batch holds settings of the batch command, its concurrency and rate
+// limit are the ones of Bench.
+type Batch struct {
+	// Format is the format of the input file: csv or ndjson, detected by
+	// the file extension if it is empty.
+	Format string
+	// Columns map CSV columns to field paths as "column=path", columns
+	// are field paths themselves by default.
+	Columns []string
+}
+
+// Health holds settings of the health command.
+type Health struct {
+	// Watching is set by --watch, status changes are streamed for Watch
+	// then, or until interrupted if it is 0.
+	Watching bool
+	Watch    time.Duration
+	Timeout  time.Duration
+}
+
+// Profile is a named target defined in the config file.
+type Profile struct {
+	Server
+	Headers map[string]string `json:"headers"`
+}
+
+// Diff holds settings of response diffing.
+type Diff struct {
+	// Ignore lists paths excluded from comparison, e.g. "**.updated_at".
+	Ignore []string `json:"ignore"`
+}
+
+// file is the layout of the JSON config file.
+type file struct {
+	Profiles map[string]*Profile `json:"profiles"`
+	Groups   map[string][]string `json:"groups"`
+	Diff     *Diff               `json:"diff"`
+	Redact   []string            `json:"redact_headers"`
+	Dial     *Dial               `json:"dial"`
+	Retry    *Retry              `json:"retry"`
 }
 
 type Input struct {
 	Body string
+	// Format is the output format of one-shot commands.
+	Format string
+	// Output is the file reports are written to, stdout by default.
+	Output string
+}
+
+// Data returns the body, reading it from a file if it is given as "@path".
+func (i *Input) Data() (string, error) {
+	if !strings.HasPrefix(i.Body, "@") {
+		return i.Body, nil
+	}
+	b, err := ioutil.ReadFile(i.Body[1:])
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read json body")
+	}
+	return string(b), nil
+}
+
+// Proxy holds settings of the proxy command.
+type Proxy struct {
+	Upstream string
+	// Record is the file proxied calls are recorded to.
+	Record string
+}
+
+// Mock holds settings of the mock server started by the serve command.
+type Mock struct {
+	Stubs  string
+	Random bool
+}
+
+// String describes the provider without secrets.
+func (a *Auth) String() string {
+	switch {
+	case a.TokenFile != "":
+		return fmt.Sprintf("token file %s", a.TokenFile)
+	case a.TokenURL != "":
+		return fmt.Sprintf("oauth2 client credentials %s (client %s)", a.TokenURL, a.ClientID)
+	case a.Exec != "":
+		return fmt.Sprintf("token command `%s`", a.Exec)
+	}
+	return "none"
 }
 
 func (s *Server) Address() (addr string) {
+	if s.Target != "" {
+		return s.Target
+	}
 	addr = s.Host
 	if s.Port != "" {
 		addr = fmt.Sprintf("%s:%s", addr, s.Port)
@@ -55,20 +221,31 @@ func registerCfg(args []string) (cfg *Config, err error) {
 		Default: new(Default),
 		Server:  new(Server),
 		Input:   new(Input),
+		Mock:    new(Mock),
+		Proxy:   new(Proxy),
+		Bench:   new(Bench),
+		Batch:   new(Batch),
+		Health:  new(Health),
+		Diff:    new(Diff),
 	}
 
+	fs.StringVar(&cfg.File, "config", "", "JSON config file with profiles")
+
 	fs.StringVar(&cfg.Describe, "desc", "", "describe only")
 
-	fs.StringVar(&cfg.Input.Body, "json", "", "json body")
+	fs.StringVar(&cfg.Input.Body, "json", "", "json body, @file reads it from the file")
+	fs.StringVar(&cfg.Input.Format, "format", FormatText, "output format of one-shot commands: text or json")
+	fs.StringVar(&cfg.Input.Output, "output", "", "file reports of one-shot commands are written to, stdout by default")
 
 	fs.StringSliceVar(&cfg.Default.ProtoPath, "path", nil, "proto path")
 	fs.StringSliceVar(&cfg.Default.ProtoFile, "file", nil, "proto files path")
+	fs.StringSliceVar(&cfg.Default.Protoset, "protoset", nil, "compiled FileDescriptorSet files, used instead of --file")
 	fs.StringVar(&cfg.Default.Package, "package", "nil", "default package")
 	fs.StringVar(&cfg.Default.Service, "service", "nil", "default service")
-	fs.StringVar(&cfg.Default.Method, "method", "nil", "default service")
+	fs.StringVar(&cfg.Default.Method, "method", "nil", "default method, or a fully qualified one like pkg.Service/Method")
 
-	fs.StringVar(&cfg.Server.Host, "host", "localhost", "gRPC server host")
-	fs.StringVar(&cfg.Server.Port, "port", "50051", "gRPC server port")
+	fs.StringVar(&cfg.Server.Host, "host", "localhost", "gRPC server host, or the listen host of the serve command")
+	fs.StringVar(&cfg.Server.Port, "port", "50051", "gRPC server port, or the listen port of the serve command")
 	fs.BoolVar(&cfg.Server.TLS, "tls", false, "use a secure TLS connection")
 	fs.StringVar(&cfg.Server.CACert, "cacert", "", "the CA certificate file for verifying the server")
 	fs.StringVar(
@@ -79,20 +256,115 @@ func registerCfg(args []string) (cfg *Config, err error) {
 		"certkey", "", "the private key file for mutual TLS auth. it must be provided with --cert.")
 	fs.StringVar(&cfg.Server.Name,
 		"servername", "", "override the server name used to verify the hostname (ignored if --tls is disabled)")
+	fs.StringVar(&cfg.Server.Compression, "compression", "none", "compression of requests: gzip or none")
+	fs.StringVar(&cfg.Server.Target, "target", "",
+		"target used instead of --host and --port: unix:///path, unix-abstract:name, dns:///host:port or host1:port,host2:port")
+	cfg.Server.Dial.Timeout = Duration(DefaultDialTimeout)
+	cfg.Server.Dial.register(fs)
+	fs.StringVar(&cfg.Server.Balancer, "lb", "pick_first", "load balancing of targets with several addresses: pick_first or round_robin")
+	fs.StringVar(&cfg.Server.Protocol, "protocol", "grpc", "protocol of calls: grpc, grpc-web, grpc-web-text, connect or connect-json")
+	cfg.Server.Retry.register(fs)
+
+	fs.StringVar(&cfg.Mock.Stubs, "stubs", "", "directory with JSON stub responses for the serve command")
+	fs.BoolVar(&cfg.Mock.Random, "random", false, "answer unstubbed methods of the serve command with random data")
+	fs.StringVar(&cfg.Faults, "faults", "", "JSON file with faults injected by the serve and proxy commands")
+
+	fs.StringVar(&cfg.Proxy.Upstream, "upstream", "", "server the proxy command forwards calls to, in the --target format")
+	fs.StringVar(&cfg.Proxy.Record, "record", "", "file calls of the proxy command are recorded to")
+
+	fs.IntVarP(&cfg.Bench.Concurrency, "concurrency", "c", 50, "number of concurrent workers of the bench and batch commands")
+	fs.IntVarP(&cfg.Bench.Requests, "requests", "n", 0, "number of requests sent by the bench command, 200 if --duration is not set")
+	fs.DurationVar(&cfg.Bench.Duration, "duration", 0, "duration of the bench command, e.g. 30s")
+	fs.IntVar(&cfg.Bench.RPS, "rps", 0, "requests per second limit of the bench and batch commands, unlimited by default")
+
+	fs.StringVar(&cfg.Batch.Format, "input-format", "", "format of the batch command input: csv or ndjson, by the file extension by default")
+	fs.StringSliceVar(&cfg.Batch.Columns, "columns", nil, "CSV columns mapped to request fields of the batch command, e.g. id=notification.id")
+
+	fs.DurationVar(&cfg.Health.Watch, "watch", 0, "stream status changes in the health command for the period, until interrupted if it is 0")
+	fs.DurationVar(&cfg.Health.Timeout, "health-timeout", 5*time.Second, "deadline of the health check")
+
+	fs.StringSliceVar(&cfg.Diff.Ignore, "diff-ignore", nil, "paths ignored by the diff command, e.g. **.updated_at")
+
+	fs.StringVar(&cfg.Server.Auth.TokenFile, "token-file", "", "file with a bearer token sent with every call")
+	fs.StringVar(&cfg.Server.Auth.TokenURL, "oauth2-token-url", "", "OAuth2 token endpoint for the client credentials grant")
+	fs.StringVar(&cfg.Server.Auth.ClientID, "oauth2-client-id", "", "OAuth2 client ID")
+	fs.StringVar(&cfg.Server.Auth.ClientSecret, "oauth2-client-secret", "", "OAuth2 client secret")
+	fs.StringSliceVar(&cfg.Server.Auth.Scopes, "oauth2-scopes", nil, "OAuth2 scopes")
+	fs.StringVar(&cfg.Server.Auth.Exec, "token-exec", "", "shell command printing a bearer token")
+	fs.StringVar(&cfg.JWKS, "jwks", "", "JWKS file verifying signatures of JWT tokens")
+	fs.StringSliceVar(&cfg.Redact, "redact-headers", nil,
+		"patterns of headers masked in output, history and recordings (default authorization,cookie,set-cookie,x-api-key)")
+	fs.StringVar(&cfg.History, "history", "", "file the shell history is saved to, secrets are masked")
+	fs.BoolVarP(&cfg.Verbose, "verbose", "v", false, "show headers, trailers and message sizes of calls")
+	fs.StringVar(&cfg.Listen, "listen", ":8080", "listen address of the gateway and proxy commands")
 
 	fs.BoolVarP(&cfg.help, "help", "h", false, "display help text and exit")
 
-	if err = fs.Parse(os.Args); err != nil {
+	if err = fs.Parse(args); err != nil {
 		return nil, errors.Wrap(err, "failed to parse command line arguments")
 	}
+	cfg.Health.Watching = fs.Changed("watch")
+	// The first argument is the program name.
+	if fs.NArg() > 1 {
+		cfg.Command = fs.Arg(1)
+		cfg.Args = fs.Args()[2:]
+	}
 
 	if cfg.help {
 		fmt.Println(fs.FlagUsages())
 		os.Exit(0)
 	}
+	if cfg.File != "" {
+		if err = cfg.load(cfg.File, fs); err != nil {
+			return nil, err
+		}
+	}
 	return
 }
 
+// load applies the config file, flags given on the command line take
+// precedence over it.
+func (cfg *Config) load(path string, fs *pflag.FlagSet) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read config file")
+	}
+	var f file
+	if err = json.Unmarshal(b, &f); err != nil {
+		return errors.Wrap(err, "failed to parse config file")
+	}
+	cfg.Profiles = f.Profiles
+	cfg.Groups = f.Groups
+	if len(cfg.Redact) == 0 {
+		cfg.Redact = f.Redact
+	}
+	if f.Diff != nil {
+		cfg.Diff.Ignore = append(cfg.Diff.Ignore, f.Diff.Ignore...)
+	}
+	if f.Dial != nil {
+		dial, settings := *f.Dial, make(map[string]bool)
+		for _, name := range DialSettings() {
+			settings[name] = true
+		}
+		fs.Visit(func(fl *pflag.Flag) {
+			if settings[fl.Name] && err == nil {
+				err = dial.Set(fl.Name, fl.Value.String())
+			}
+		})
+		if err != nil {
+			return err
+		}
+		cfg.Server.Dial = dial
+	}
+	if f.Retry != nil {
+		cfg.Server.Retry.merge(f.Retry, fs)
+	}
+	if _, err = cfg.Server.Retry.RetryableCodes(); err != nil {
+		return err
+	}
+	return nil
+}
+
 func Init(args []string) (cfg *Config, err error) {
 	return registerCfg(args)
 }
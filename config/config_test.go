@@ -0,0 +1,27 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// initWithFile inits the config from the args with a config file of the
+// body, which is passed before the args.
+func initWithFile(t *testing.T, body string, args ...string) *Config {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	if err = ioutil.WriteFile(path, []byte(body), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Init(append([]string{"grpc_cli", "--config", path}, args...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
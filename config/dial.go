@@ -0,0 +1,120 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// DefaultDialTimeout is used when Dial.Timeout is not set.
+const DefaultDialTimeout = 10 * time.Second
+
+// Dial holds connection settings, zero values keep gRPC defaults.
+type Dial struct {
+	Timeout               Duration `json:"timeout"`
+	MaxRecvMsgSize        int      `json:"max_recv_msg_size"`
+	MaxSendMsgSize        int      `json:"max_send_msg_size"`
+	KeepaliveTime         Duration `json:"keepalive_time"`
+	KeepaliveTimeout      Duration `json:"keepalive_timeout"`
+	KeepaliveWithoutCalls bool     `json:"keepalive_without_calls"`
+	InitialWindowSize     int32    `json:"initial_window_size"`
+	InitialConnWindowSize int32    `json:"initial_conn_window_size"`
+	UserAgent             string   `json:"user_agent"`
+	Authority             string   `json:"authority"`
+	BackoffBaseDelay      Duration `json:"backoff_base_delay"`
+	BackoffMaxDelay       Duration `json:"backoff_max_delay"`
+}
+
+// register binds flags to the settings, current values are the defaults.
+func (d *Dial) register(fs *pflag.FlagSet) {
+	fs.Var(&d.Timeout, "dial-timeout", "connection timeout")
+	fs.IntVar(&d.MaxRecvMsgSize, "max-recv-msg-size", d.MaxRecvMsgSize, "max size of received messages in bytes, 4MB by default")
+	fs.IntVar(&d.MaxSendMsgSize, "max-send-msg-size", d.MaxSendMsgSize, "max size of sent messages in bytes, unlimited by default")
+	fs.Var(&d.KeepaliveTime, "keepalive-time", "interval of keepalive pings, disabled by default")
+	fs.Var(&d.KeepaliveTimeout, "keepalive-timeout", "time to wait for a keepalive ping ack with --keepalive-time, 20s by default")
+	fs.BoolVar(&d.KeepaliveWithoutCalls, "keepalive-without-calls", d.KeepaliveWithoutCalls, "send keepalive pings of --keepalive-time without active calls")
+	fs.Int32Var(&d.InitialWindowSize, "initial-window-size", d.InitialWindowSize, "initial HTTP/2 stream window size in bytes")
+	fs.Int32Var(&d.InitialConnWindowSize, "initial-conn-window-size", d.InitialConnWindowSize, "initial HTTP/2 connection window size in bytes")
+	fs.StringVar(&d.UserAgent, "user-agent", d.UserAgent, "user agent prepended to the gRPC one")
+	fs.StringVar(&d.Authority, "authority", d.Authority, "value of the :authority header, also the TLS server name unless --servername is set")
+	fs.Var(&d.BackoffBaseDelay, "backoff-base-delay", "delay of the first reconnect attempt, 1s by default")
+	fs.Var(&d.BackoffMaxDelay, "backoff-max-delay", "max delay between reconnect attempts, 120s by default")
+}
+
+// Set changes the setting named like its flag, e.g. "max-recv-msg-size".
+func (d *Dial) Set(name, value string) error {
+	// Invalid values may be partially applied, so a copy is changed.
+	changed := *d
+	fs := pflag.NewFlagSet("dial", pflag.ContinueOnError)
+	changed.register(fs)
+	if err := fs.Set(name, value); err != nil {
+		return errors.Wrapf(err, "failed to set %s", name)
+	}
+	*d = changed
+	return nil
+}
+
+// DialSettings returns names of dial settings accepted by Set.
+func DialSettings() (names []string) {
+	fs := pflag.NewFlagSet("dial", pflag.ContinueOnError)
+	new(Dial).register(fs)
+	fs.VisitAll(func(f *pflag.Flag) {
+		names = append(names, f.Name)
+	})
+	return
+}
+
+// String lists the settings that are set as "name=value".
+func (d *Dial) String() string {
+	fs := pflag.NewFlagSet("dial", pflag.ContinueOnError)
+	fs.SortFlags = false
+	d.register(fs)
+	var set []string
+	fs.VisitAll(func(f *pflag.Flag) {
+		if f.Name == "dial-timeout" && time.Duration(d.Timeout) == DefaultDialTimeout {
+			return
+		}
+		switch v := f.Value.String(); v {
+		case "", "0", "0s", "false":
+		default:
+			set = append(set, f.Name+"="+v)
+		}
+	})
+	return strings.Join(set, ", ")
+}
+
+// Duration is a time.Duration written as "10s" in flags and config files.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set parses the duration, it implements pflag.Value.
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d *Duration) Type() string {
+	return "duration"
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.Wrap(err, "duration must be a string like \"10s\"")
+	}
+	return d.Set(s)
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDialConfig(t *testing.T) {
+	body := `{"dial": {"max_recv_msg_size": 16777216, "keepalive_time": "30s", "user_agent": "file"}}`
+	cfg := initWithFile(t, body, "--user-agent", "flag")
+	d := cfg.Server.Dial
+	if d.MaxRecvMsgSize != 16777216 || time.Duration(d.KeepaliveTime) != 30*time.Second {
+		t.Errorf("file settings not applied: %+v", d)
+	}
+	if d.UserAgent != "flag" {
+		t.Errorf("user agent %q, the flag must take precedence", d.UserAgent)
+	}
+
+	if err := d.Set("backoff-max-delay", "5s"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("max-recv-msg-size", "big"); err == nil {
+		t.Error("invalid size accepted")
+	}
+	want := "max-recv-msg-size=16777216, keepalive-time=30s, user-agent=flag, backoff-max-delay=5s"
+	if got := d.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
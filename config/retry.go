@@ -0,0 +1,172 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc/codes"
+)
+
+// Defaults of the retry policy.
+const (
+	DefaultRetryInitialBackoff = 100 * time.Millisecond
+	DefaultRetryMaxBackoff     = 5 * time.Second
+	DefaultRetryMultiplier     = 2
+)
+
+// DefaultRetryCodes are retried when Retry.Codes is empty.
+var DefaultRetryCodes = []string{"UNAVAILABLE"}
+
+// Retry is the policy of unary and server streaming calls, calls are made
+// once unless MaxAttempts is above 1.
+type Retry struct {
+	MaxAttempts    int      `json:"max_attempts"`
+	InitialBackoff Duration `json:"initial_backoff"`
+	MaxBackoff     Duration `json:"max_backoff"`
+	Multiplier     float64  `json:"backoff_multiplier"`
+	// Codes are status codes retried, e.g. "UNAVAILABLE".
+	Codes []string `json:"retryable_codes"`
+	// HedgingDelay hedges unary calls instead of retrying them: attempts
+	// are sent in parallel, a new one after the delay or a failure with a
+	// retryable code, and the first success wins.
+	HedgingDelay Duration `json:"hedging_delay"`
+}
+
+// register binds flags to the settings, current values are the defaults.
+func (r *Retry) register(fs *pflag.FlagSet) {
+	fs.IntVar(&r.MaxAttempts, "retry-max-attempts", r.MaxAttempts, "max attempts of a call, including the first one, calls are not retried by default")
+	fs.Var(&r.InitialBackoff, "retry-initial-backoff", "delay before the first retry, 100ms by default")
+	fs.Var(&r.MaxBackoff, "retry-max-backoff", "max delay between retries, 5s by default")
+	fs.Float64Var(&r.Multiplier, "retry-multiplier", r.Multiplier, "growth of the delay after each retry, 2 by default")
+	fs.StringSliceVar(&r.Codes, "retry-codes", r.Codes, "status codes of retried calls (default UNAVAILABLE)")
+	fs.Var(&r.HedgingDelay, "retry-hedging-delay", "delay between parallel attempts of hedged unary calls, calls are retried with backoff if it is 0")
+}
+
+// Set changes the setting named like its flag without the "retry-"
+// prefix, e.g. "max-attempts".
+func (r *Retry) Set(name, value string) error {
+	changed := *r
+	// Slices are appended to by the flag, so codes are replaced.
+	if name == "codes" {
+		changed.Codes = nil
+	}
+	fs := pflag.NewFlagSet("retry", pflag.ContinueOnError)
+	changed.register(fs)
+	if err := fs.Set("retry-"+name, value); err != nil {
+		return errors.Wrapf(err, "failed to set %s", name)
+	}
+	if _, err := changed.RetryableCodes(); err != nil {
+		return err
+	}
+	*r = changed
+	return nil
+}
+
+// RetrySettings returns names of retry settings accepted by Set.
+func RetrySettings() (names []string) {
+	fs := pflag.NewFlagSet("retry", pflag.ContinueOnError)
+	fs.SortFlags = false
+	new(Retry).register(fs)
+	fs.VisitAll(func(f *pflag.Flag) {
+		names = append(names, strings.TrimPrefix(f.Name, "retry-"))
+	})
+	return
+}
+
+// Enabled reports whether calls are retried.
+func (r *Retry) Enabled() bool {
+	return r.MaxAttempts > 1
+}
+
+// Backoff returns the delay before the given retry, starting with 1,
+// without jitter.
+func (r *Retry) Backoff(retry int) time.Duration {
+	initial, max := time.Duration(r.InitialBackoff), time.Duration(r.MaxBackoff)
+	if initial <= 0 {
+		initial = DefaultRetryInitialBackoff
+	}
+	if max <= 0 {
+		max = DefaultRetryMaxBackoff
+	}
+	delay := float64(initial)
+	for i := 1; i < retry && delay < float64(max); i++ {
+		delay *= r.multiplier()
+	}
+	if delay > float64(max) {
+		return max
+	}
+	return time.Duration(delay)
+}
+
+// RetryableCodes parses the codes, DefaultRetryCodes are used if none are
+// given.
+func (r *Retry) RetryableCodes() ([]codes.Code, error) {
+	names := r.Codes
+	if len(names) == 0 {
+		names = DefaultRetryCodes
+	}
+	var parsed []codes.Code
+	for _, name := range names {
+		var code codes.Code
+		quoted, _ := json.Marshal(strings.ToUpper(strings.TrimSpace(name)))
+		if err := code.UnmarshalJSON(quoted); err != nil {
+			return nil, errors.Errorf("unknown status code %s", name)
+		}
+		parsed = append(parsed, code)
+	}
+	return parsed, nil
+}
+
+// String describes the policy, it is empty if calls are not retried.
+func (r *Retry) String() string {
+	if !r.Enabled() {
+		return ""
+	}
+	names := r.Codes
+	if len(names) == 0 {
+		names = DefaultRetryCodes
+	}
+	if r.HedgingDelay > 0 {
+		return fmt.Sprintf("%d attempts, hedging delay %s, codes %s",
+			r.MaxAttempts, r.HedgingDelay, strings.Join(names, ","))
+	}
+	return fmt.Sprintf("%d attempts, backoff %s..%s x%g, codes %s",
+		r.MaxAttempts, r.Backoff(1), r.Backoff(r.MaxAttempts-1), r.multiplier(), strings.Join(names, ","))
+}
+
+func (r *Retry) multiplier() float64 {
+	if r.Multiplier < 1 {
+		return DefaultRetryMultiplier
+	}
+	return r.Multiplier
+}
+
+// merge applies settings of the config file that are not set with flags.
+func (r *Retry) merge(file *Retry, fs *pflag.FlagSet) {
+	set := func(name string) bool {
+		f := fs.Lookup(name)
+		return f != nil && f.Changed
+	}
+	if !set("retry-max-attempts") {
+		r.MaxAttempts = file.MaxAttempts
+	}
+	if !set("retry-initial-backoff") {
+		r.InitialBackoff = file.InitialBackoff
+	}
+	if !set("retry-max-backoff") {
+		r.MaxBackoff = file.MaxBackoff
+	}
+	if !set("retry-multiplier") {
+		r.Multiplier = file.Multiplier
+	}
+	if !set("retry-codes") {
+		r.Codes = file.Codes
+	}
+	if !set("retry-hedging-delay") {
+		r.HedgingDelay = file.HedgingDelay
+	}
+}
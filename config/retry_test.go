@@ -0,0 +1,42 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryConfig(t *testing.T) {
+	body := `{"retry": {"max_attempts": 5, "max_backoff": "1s", "retryable_codes": ["ABORTED"]}}`
+	cfg := initWithFile(t, body, "--retry-codes", "unavailable,resource_exhausted")
+	r := cfg.Server.Retry
+	if r.MaxAttempts != 5 || time.Duration(r.MaxBackoff) != time.Second {
+		t.Errorf("file settings not applied: %+v", r)
+	}
+	if codes, err := r.RetryableCodes(); err != nil || len(codes) != 2 {
+		t.Errorf("the flag must take precedence, got %v, %v", codes, err)
+	}
+	for retry, want := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 5: time.Second} {
+		if got := r.Backoff(retry); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", retry, got, want)
+		}
+	}
+
+	if err := r.Set("codes", "aborted"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Set("codes", "FLAKY"); err == nil {
+		t.Error("unknown code accepted")
+	}
+	want := "5 attempts, backoff 100ms..800ms x2, codes aborted"
+	if got := r.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	if err := r.Set("hedging-delay", "50ms"); err != nil {
+		t.Fatal(err)
+	}
+	want = "5 attempts, hedging delay 50ms, codes aborted"
+	if got := r.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
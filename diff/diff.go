@@ -0,0 +1,216 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Kind is a kind of difference between two values.
+type Kind int
+
+const (
+	Changed Kind = iota
+	Added
+	Removed
+)
+
+// Change is a difference found at Path, e.g. "order.items[1].sku".
+type Change struct {
+	Path string
+	Kind Kind
+	Old  interface{}
+	New  interface{}
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ %s: %s", c.Path, format(c.New))
+	case Removed:
+		return fmt.Sprintf("- %s: %s", c.Path, format(c.Old))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, format(c.Old), format(c.New))
+	}
+}
+
+// JSON compares two JSON documents field by field, the order of object
+// keys is ignored. Subtrees matching any of the ignore patterns are skipped,
+// see Match for the pattern syntax.
+func JSON(a, b []byte, ignore ...string) ([]Change, error) {
+	var va, vb interface{}
+	if err := unmarshal(a, &va); err != nil {
+		return nil, errors.Wrap(err, "diff: failed to decode the first document")
+	}
+	if err := unmarshal(b, &vb); err != nil {
+		return nil, errors.Wrap(err, "diff: failed to decode the second document")
+	}
+	return Values(va, vb, ignore...), nil
+}
+
+// Values compares two values decoded from JSON.
+func Values(a, b interface{}, ignore ...string) []Change {
+	d := &differ{}
+	for _, pattern := range ignore {
+		if pattern != "" {
+			d.ignore = append(d.ignore, segments(pattern))
+		}
+	}
+	d.compare("", a, b)
+	return d.changes
+}
+
+type differ struct {
+	ignore  [][]string
+	changes []Change
+}
+
+func unmarshal(b []byte, v interface{}) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (d *differ) compare(path string, a, b interface{}) {
+	for _, pattern := range d.ignore {
+		if match(pattern, segments(path)) {
+			return
+		}
+	}
+	switch {
+	case a == nil && b == nil:
+		return
+	case a == nil:
+		d.changes = append(d.changes, Change{Path: rootPath(path), Kind: Added, New: b})
+		return
+	case b == nil:
+		d.changes = append(d.changes, Change{Path: rootPath(path), Kind: Removed, Old: a})
+		return
+	}
+
+	switch va := a.(type) {
+	case map[string]interface{}:
+		vb, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, k := range keys(va, vb) {
+			d.compare(join(path, k), va[k], vb[k])
+		}
+		return
+	case []interface{}:
+		vb, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(va) || i < len(vb); i++ {
+			var ea, eb interface{}
+			if i < len(va) {
+				ea = va[i]
+			}
+			if i < len(vb) {
+				eb = vb[i]
+			}
+			d.compare(fmt.Sprintf("%s[%d]", path, i), ea, eb)
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		d.changes = append(d.changes, Change{Path: rootPath(path), Kind: Changed, Old: a, New: b})
+	}
+}
+
+// Match reports whether the pattern matches the path or one of its parents.
+// Pattern segments are separated by dots, "*" matches any field, "[*]" any
+// index and "**" any number of segments. Field names are compared ignoring
+// case and underscores, so both proto and JSON names can be used:
+// "items[*].updated_at" matches "items[3].updatedAt".
+func Match(pattern, path string) bool {
+	return match(segments(pattern), segments(path))
+}
+
+func match(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if match(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	isIndex := strings.HasPrefix(path[0], "[")
+	switch {
+	case pattern[0] == "*" && !isIndex, pattern[0] == "[*]" && isIndex:
+	case normalize(pattern[0]) != normalize(path[0]):
+		return false
+	}
+	return match(pattern[1:], path[1:])
+}
+
+// segments splits "items[1].sku" to ["items", "[1]", "sku"].
+func segments(path string) []string {
+	var segs []string
+	for _, part := range strings.Split(path, ".") {
+		for part != "" {
+			i := strings.Index(part[1:], "[") + 1
+			if i == 0 {
+				segs = append(segs, part)
+				break
+			}
+			segs = append(segs, part[:i])
+			part = part[i:]
+		}
+	}
+	return segs
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
+
+func keys(a, b map[string]interface{}) []string {
+	ks := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		ks = append(ks, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			ks = append(ks, k)
+		}
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func rootPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+func format(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,71 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	a := []byte(`{"id": "1", "status": "NEW", "items": [{"sku": "a"}, {"sku": "b"}], "meta": {"x": 1}}`)
+	b := []byte(`{"meta": {"x": 1}, "status": "DONE", "items": [{"sku": "a"}], "total": 2, "id": "1"}`)
+
+	changes, err := JSON(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	want := []string{
+		`- items[1]: {"sku":"b"}`,
+		`~ status: "NEW" -> "DONE"`,
+		`+ total: 2`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestJSONEqual(t *testing.T) {
+	changes, err := JSON([]byte(`{"a": 1, "b": [1, 2]}`), []byte(`{"b": [1, 2], "a": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+}
+
+func TestJSONIgnore(t *testing.T) {
+	a := []byte(`{"updatedAt": "1", "items": [{"sku": "a", "ts": 1}], "meta": {"createdAt": "1"}}`)
+	b := []byte(`{"updatedAt": "2", "items": [{"sku": "a", "ts": 2}], "meta": {"createdAt": "2"}}`)
+
+	changes, err := JSON(a, b, "updated_at", "items[*].ts", "**.created_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"order.id", "order.id", true},
+		{"order", "order.items[0].sku", true},
+		{"order.*.sku", "order.item.sku", true},
+		{"order.*.sku", "order.items[0].sku", false},
+		{"order.items[*].sku", "order.items[3].sku", true},
+		{"**.sku", "order.items[3].sku", true},
+		{"**.sku", "order.items[3].name", false},
+		{"order_id", "orderId", true},
+	} {
+		if got := Match(tc.pattern, tc.path); got != tc.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+// Package gateway serves methods of the loaded protos as HTTP/JSON
+// endpoints and forwards them as gRPC calls.
+//
+// Every method is available at "POST /pkg.Service/Method" with the request
+// message as the body. Methods with google.api.http options are also bound
+// to their path templates, with fields taken from the path, the query and
+// the body as the rule defines.
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/config"
+	"github.com/alexej-v/grpc_cli/jsonfields"
+	"github.com/alexej-v/grpc_cli/proto"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Prefixes of HTTP headers carrying metadata.
+const (
+	metadataPrefix = "Grpc-Metadata-"
+	trailerPrefix  = "Grpc-Trailer-"
+)
+
+// skippedHeaders are HTTP headers not sent as metadata.
+var skippedHeaders = []string{
+	"accept", "accept-encoding", "accept-language", "connection", "content-length", "content-type",
+	"host", "keep-alive", "origin", "referer", "te", "trailer", "transfer-encoding", "upgrade", "user-agent",
+}
+
+// Gateway translates HTTP/JSON requests to calls of the client.
+type Gateway struct {
+	cli client.Client
+	// methods are the methods by their "/pkg.Service/Method" paths.
+	methods   map[string]*desc.MethodDescriptor
+	bindings  []*binding
+	marshaler *jsonpb.Marshaler
+}
+
+// New returns a gateway of all methods of the spec.
+func New(spec proto.Spec, cli client.Client) (*Gateway, error) {
+	g := &Gateway{
+		cli:       cli,
+		methods:   make(map[string]*desc.MethodDescriptor),
+		marshaler: &jsonpb.Marshaler{OrigName: true, EmitDefaults: true},
+	}
+	for _, sd := range spec.Services() {
+		for _, md := range sd.GetMethods() {
+			g.methods["/"+sd.GetFullyQualifiedName()+"/"+md.GetName()] = md
+			rule := httpRule(md)
+			if rule == nil {
+				continue
+			}
+			bs, err := bindings(md, rule)
+			if err != nil {
+				return nil, errors.Wrap(err, "gateway")
+			}
+			g.bindings = append(g.bindings, bs...)
+		}
+	}
+	return g, nil
+}
+
+// Serve starts the gateway on the listen address, calls are sent to the
+// configured server.
+func Serve(cfg *config.Config, spec proto.Spec) error {
+	// Calls are sent once like by grpc-gateway, HTTP clients retry them.
+	srv := *cfg.Server
+	srv.Retry = config.Retry{}
+	cli, err := client.NewClientFromConfig(&srv)
+	if err != nil {
+		return errors.Wrap(err, "gateway: failed to create new client")
+	}
+	defer cli.Close()
+	g, err := New(spec, cli)
+	if err != nil {
+		return err
+	}
+	for _, b := range g.bindings {
+		log.Printf("%s /%s -> %s", b.httpMethod, b.tmpl, b.md.GetFullyQualifiedName())
+	}
+	log.Printf("gateway is listening on %s, forwarding to %s", cfg.Listen, cfg.Server.Address())
+	return errors.Wrap(http.ListenAndServe(cfg.Listen, g), "gateway: failed to serve")
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, vars := g.route(r)
+	if b == nil {
+		g.writeError(w, status.Errorf(codes.NotFound, "no method for %s %s", r.Method, r.URL.Path))
+		return
+	}
+	md := b.md
+	if md.IsClientStreaming() {
+		g.writeError(w, status.Errorf(codes.Unimplemented, "client streaming method %s is not supported", md.GetFullyQualifiedName()))
+		return
+	}
+	req, err := g.request(r, b, vars)
+	if err != nil {
+		g.writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	ctx := metadata.NewOutgoingContext(r.Context(), incomingMetadata(r.Header))
+
+	if md.IsServerStreaming() {
+		err = g.stream(ctx, w, b, req)
+	} else {
+		err = g.unary(ctx, w, b, req)
+	}
+	log.Printf("%s %s -> %s: %s", r.Method, r.URL.Path, md.GetFullyQualifiedName(), status.Code(err))
+}
+
+// route returns the binding of the request and values of its path
+// variables, or nil.
+func (g *Gateway) route(r *http.Request) (*binding, map[string]string) {
+	if md, ok := g.methods[r.URL.Path]; ok && r.Method == http.MethodPost {
+		return &binding{httpMethod: http.MethodPost, md: md, body: "*"}, nil
+	}
+	for _, b := range g.bindings {
+		if b.httpMethod != r.Method {
+			continue
+		}
+		if vars, ok := b.tmpl.match(r.URL.EscapedPath()); ok {
+			return b, vars
+		}
+	}
+	return nil, nil
+}
+
+// request builds the request message from the body, path variables and
+// query parameters.
+func (g *Gateway) request(r *http.Request, b *binding, vars map[string]string) (*dynamic.Message, error) {
+	md := b.md.GetInputType()
+	req := dynamic.NewMessage(md)
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read body")
+	}
+	if len(bytes.TrimSpace(body)) > 0 && b.body != "" {
+		if b.body != "*" {
+			if body, err = json.Marshal(map[string]json.RawMessage{b.body: body}); err != nil {
+				return nil, errors.Wrap(err, "invalid JSON body")
+			}
+		}
+		if err = req.UnmarshalMergeJSON(body); err != nil {
+			return nil, errors.Wrap(err, "invalid JSON body")
+		}
+	}
+
+	f := jsonfields.Object{}
+	for name, v := range vars {
+		if err = f.Set(md, name, v); err != nil {
+			return nil, err
+		}
+	}
+	if b.body != "*" {
+		for key, vals := range r.URL.Query() {
+			if _, ok := vars[key]; ok || key == b.body {
+				continue
+			}
+			if err = f.Set(md, key, vals...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(f) == 0 {
+		return req, nil
+	}
+	js, err := json.Marshal(f)
+	if err == nil {
+		err = req.UnmarshalMergeJSON(js)
+	}
+	return req, errors.Wrap(err, "invalid path or query parameters")
+}
+
+func (g *Gateway) unary(ctx context.Context, w http.ResponseWriter, b *binding, req *dynamic.Message) error {
+	var header, trailer metadata.MD
+	resp := dynamic.NewMessage(b.md.GetOutputType())
+	err := g.cli.Invoke(ctx, b.md.GetFullyQualifiedName(), req, resp, grpc.Header(&header), grpc.Trailer(&trailer))
+	writeMetadata(w.Header(), metadataPrefix, header)
+	writeMetadata(w.Header(), trailerPrefix, trailer)
+	if err != nil {
+		g.writeError(w, err)
+		return err
+	}
+	js, err := g.json(resp, b.responseBody)
+	if err != nil {
+		g.writeError(w, status.Errorf(codes.Internal, "failed to marshal response: %v", err))
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+	return nil
+}
+
+// stream writes server streaming responses as newline delimited JSON
+// objects with a "result", or an "error" if the call fails after headers
+// are sent.
+func (g *Gateway) stream(ctx context.Context, w http.ResponseWriter, b *binding, req *dynamic.Message) error {
+	s, err := g.cli.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, b.md.GetFullyQualifiedName())
+	if err == nil {
+		err = s.SendMsg(req)
+	}
+	if err == nil {
+		err = s.CloseSend()
+	}
+	if err != nil {
+		g.writeError(w, err)
+		return err
+	}
+	flusher, _ := w.(http.Flusher)
+	started := false
+	for {
+		resp := dynamic.NewMessage(b.md.GetOutputType())
+		err = s.RecvMsg(resp)
+		if !started {
+			header, _ := s.Header()
+			writeMetadata(w.Header(), metadataPrefix, header)
+			if err != nil && err != io.EOF {
+				g.writeError(w, err)
+				return err
+			}
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			started = true
+		}
+		if err == io.EOF {
+			return nil
+		}
+		line := map[string]json.RawMessage{}
+		if err != nil {
+			line["error"] = g.statusJSON(err)
+		} else if line["result"], err = g.json(resp, b.responseBody); err != nil {
+			line["error"] = g.statusJSON(status.Errorf(codes.Internal, "failed to marshal response: %v", err))
+		}
+		js, _ := json.Marshal(line)
+		w.Write(append(js, '\n'))
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if _, failed := line["error"]; failed {
+			return err
+		}
+	}
+}
+
+// json marshals the message, or its field if field is set.
+func (g *Gateway) json(msg *dynamic.Message, field string) ([]byte, error) {
+	js, err := msg.MarshalJSONPB(g.marshaler)
+	if err != nil || field == "" {
+		return js, err
+	}
+	var obj map[string]json.RawMessage
+	if err = json.Unmarshal(js, &obj); err != nil {
+		return nil, err
+	}
+	if v, ok := obj[field]; ok {
+		return v, nil
+	}
+	return []byte("null"), nil
+}
+
+// statusJSON returns the google.rpc.Status of the error as JSON, details
+// are omitted if their types are unknown.
+func (g *Gateway) statusJSON(err error) json.RawMessage {
+	st := status.Convert(err).Proto()
+	var buf bytes.Buffer
+	if g.marshaler.Marshal(&buf, st) != nil {
+		buf.Reset()
+		st.Details = nil
+		g.marshaler.Marshal(&buf, st)
+	}
+	return buf.Bytes()
+}
+
+func (g *Gateway) writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus(status.Code(err)))
+	w.Write(g.statusJSON(err))
+}
+
+// incomingMetadata maps request headers to metadata, the Grpc-Metadata-
+// prefix is dropped and values of binary keys are base64 decoded.
+func incomingMetadata(h http.Header) metadata.MD {
+	md := metadata.MD{}
+	for k, vals := range h {
+		k = strings.ToLower(k)
+		if contains(skippedHeaders, k) {
+			continue
+		}
+		k = strings.TrimPrefix(k, strings.ToLower(metadataPrefix))
+		for _, v := range vals {
+			if strings.HasSuffix(k, "-bin") {
+				b, err := base64.StdEncoding.DecodeString(v)
+				if err != nil {
+					continue
+				}
+				v = string(b)
+			}
+			md[k] = append(md[k], v)
+		}
+	}
+	return md
+}
+
+func writeMetadata(h http.Header, prefix string, md metadata.MD) {
+	for k, vals := range md {
+		for _, v := range vals {
+			if strings.HasSuffix(k, "-bin") {
+				v = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			h.Add(prefix+k, v)
+		}
+	}
+}
+
+// httpStatus maps codes to HTTP statuses like grpc-gateway does.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return http.StatusRequestTimeout
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
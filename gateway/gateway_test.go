@@ -0,0 +1,254 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/proto"
+
+	gproto "github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Subsets of google/api/annotations.proto and google/api/http.proto.
+const (
+	annotationsProto = `syntax = "proto3";
+package google.api;
+import "google/api/http.proto";
+import "google/protobuf/descriptor.proto";
+extend google.protobuf.MethodOptions { HttpRule http = 72295728; }`
+
+	httpProto = `syntax = "proto3";
+package google.api;
+message HttpRule {
+  string selector = 1;
+  oneof pattern {
+    string get = 2;
+    string put = 3;
+    string post = 4;
+    string delete = 5;
+    string patch = 6;
+    CustomHttpPattern custom = 8;
+  }
+  string body = 7;
+  string response_body = 12;
+  repeated HttpRule additional_bindings = 11;
+}
+message CustomHttpPattern {
+  string kind = 1;
+  string path = 2;
+}`
+
+	libraryProto = `syntax = "proto3";
+package test.library;
+import "google/api/annotations.proto";
+
+message Book {
+  string name = 1;
+  string title = 2;
+  int32 pages = 3;
+  repeated string tags = 4;
+}
+
+service Library {
+  rpc GetBook(Book) returns (Book) {
+    option (google.api.http) = { get: "/v1/{name=shelves/*/books/*}" };
+  }
+  rpc UpdateBook(Book) returns (Book) {
+    option (google.api.http) = {
+      patch: "/v1/{name=shelves/*/books/*}"
+      body: "*"
+      additional_bindings { put: "/v1/books/{name}:replace" body: "*" }
+    };
+  }
+  rpc ListBooks(Book) returns (stream Book) {
+    option (google.api.http) = { get: "/v1/shelves/{name}/books" response_body: "title" };
+  }
+  rpc Upload(stream Book) returns (Book);
+}`
+)
+
+func loadTestSpec(t *testing.T) proto.Spec {
+	dir, err := ioutil.TempDir("", "gateway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"google/api/annotations.proto": annotationsProto,
+		"google/api/http.proto":        httpProto,
+		"library.proto":                libraryProto,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	spec, err := proto.Parse([]string{"library.proto"}, []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// echoClient answers calls with their requests, twice for streams, and
+// returns the x-id metadata as a header.
+type echoClient struct{}
+
+func (echoClient) Headers() client.Headers {
+	return client.Headers{}
+}
+
+func (echoClient) Invoke(ctx context.Context, fqrn string, req, resp interface{}, opts ...grpc.CallOption) error {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if len(md.Get("fail")) > 0 {
+		return status.Error(codes.NotFound, "book not found")
+	}
+	for _, o := range opts {
+		if h, ok := o.(grpc.HeaderCallOption); ok {
+			*h.HeaderAddr = metadata.MD{"x-id": md.Get("x-id")}
+		}
+	}
+	gproto.Merge(resp.(gproto.Message), req.(gproto.Message))
+	return nil
+}
+
+func (echoClient) NewStream(ctx context.Context, _ *grpc.StreamDesc, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+	return &echoStream{ctx: ctx}, nil
+}
+
+func (echoClient) Close() error {
+	return nil
+}
+
+type echoStream struct {
+	grpc.ClientStream
+	ctx  context.Context
+	req  gproto.Message
+	sent int
+}
+
+func (s *echoStream) SendMsg(m interface{}) error {
+	s.req = m.(gproto.Message)
+	return nil
+}
+
+func (s *echoStream) CloseSend() error {
+	return nil
+}
+
+func (s *echoStream) Header() (metadata.MD, error) {
+	return nil, nil
+}
+
+func (s *echoStream) RecvMsg(m interface{}) error {
+	if s.sent == 2 {
+		return io.EOF
+	}
+	s.sent++
+	gproto.Merge(m.(gproto.Message), s.req)
+	return nil
+}
+
+func TestGateway(t *testing.T) {
+	g, err := New(loadTestSpec(t), echoClient{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(g)
+	defer srv.Close()
+
+	tests := []struct {
+		name, method, path, body string
+		header                   http.Header
+		code                     int
+		want                     string
+	}{
+		{
+			name: "default route", method: http.MethodPost, path: "/test.library.Library/GetBook",
+			body: `{"name":"shelves/1/books/2","pages":10}`, code: http.StatusOK,
+			want: `{"name":"shelves/1/books/2","title":"","pages":10,"tags":[]}`,
+		},
+		{
+			name: "path and query", method: http.MethodGet, path: "/v1/shelves/1/books/2?pages=5&tags=a&tags=b",
+			code: http.StatusOK,
+			want: `{"name":"shelves/1/books/2","title":"","pages":5,"tags":["a","b"]}`,
+		},
+		{
+			name: "body", method: http.MethodPatch, path: "/v1/shelves/1/books/2", body: `{"title":"Go"}`,
+			code: http.StatusOK,
+			want: `{"name":"shelves/1/books/2","title":"Go","pages":0,"tags":[]}`,
+		},
+		{
+			name: "additional binding with verb", method: http.MethodPut, path: "/v1/books/b%2F1:replace", body: `{"title":"Go"}`,
+			code: http.StatusOK,
+			want: `{"name":"b/1","title":"Go","pages":0,"tags":[]}`,
+		},
+		{
+			name: "stream with response body", method: http.MethodGet, path: "/v1/shelves/s1/books?title=Go",
+			code: http.StatusOK,
+			want: "{\"result\":\"Go\"}\n{\"result\":\"Go\"}\n",
+		},
+		{
+			name: "unknown query field", method: http.MethodGet, path: "/v1/shelves/1/books/2?author=me",
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "status", method: http.MethodGet, path: "/v1/shelves/1/books/2",
+			header: http.Header{"Grpc-Metadata-Fail": {"1"}},
+			code:   http.StatusNotFound, want: `{"code":5,"message":"book not found","details":[]}`,
+		},
+		{name: "unknown path", method: http.MethodGet, path: "/v2/books", code: http.StatusNotFound},
+		{
+			name: "client streaming", method: http.MethodPost, path: "/test.library.Library/Upload",
+			code: http.StatusNotImplemented,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			req.Header.Set("X-Id", "42")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			if resp.StatusCode != tt.code {
+				t.Fatalf("expected status %d, got %d: %s", tt.code, resp.StatusCode, body)
+			}
+			if tt.want != "" && string(body) != tt.want {
+				t.Errorf("expected body %s, got %s", tt.want, body)
+			}
+			if resp.StatusCode == http.StatusOK && resp.Header.Get("Content-Type") == "application/json" &&
+				resp.Header.Get("Grpc-Metadata-X-Id") != "42" {
+				t.Errorf("expected header x-id from metadata, got %v", resp.Header)
+			}
+			if resp.StatusCode != http.StatusOK && !json.Valid(body) {
+				t.Errorf("expected a JSON error, got %s", body)
+			}
+		})
+	}
+}
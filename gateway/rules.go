@@ -0,0 +1,260 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	descpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+// binding maps HTTP requests matching a path template to a method.
+type binding struct {
+	httpMethod string
+	tmpl       *template
+	md         *desc.MethodDescriptor
+	// body is "*" for the whole request message, a field name, or empty
+	// if the request has no body.
+	body string
+	// responseBody is the response field written instead of the message.
+	responseBody string
+}
+
+// httpRule returns the google.api.http option of the method, or nil.
+func httpRule(md *desc.MethodDescriptor) *annotations.HttpRule {
+	opts := md.GetMethodOptions()
+	if opts == nil {
+		return nil
+	}
+	// Options of parsed files keep extensions as unrecognized fields, a
+	// round trip makes them known.
+	b, err := proto.Marshal(opts)
+	if err != nil {
+		return nil
+	}
+	var known descpb.MethodOptions
+	if err = proto.Unmarshal(b, &known); err != nil || !proto.HasExtension(&known, annotations.E_Http) {
+		return nil
+	}
+	ext, err := proto.GetExtension(&known, annotations.E_Http)
+	if err != nil {
+		return nil
+	}
+	rule, _ := ext.(*annotations.HttpRule)
+	return rule
+}
+
+// bindings returns the bindings of the rule and its additional bindings.
+func bindings(md *desc.MethodDescriptor, rule *annotations.HttpRule) ([]*binding, error) {
+	var method, path string
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		method, path = http.MethodGet, p.Get
+	case *annotations.HttpRule_Put:
+		method, path = http.MethodPut, p.Put
+	case *annotations.HttpRule_Post:
+		method, path = http.MethodPost, p.Post
+	case *annotations.HttpRule_Delete:
+		method, path = http.MethodDelete, p.Delete
+	case *annotations.HttpRule_Patch:
+		method, path = http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Custom:
+		method, path = strings.ToUpper(p.Custom.GetKind()), p.Custom.GetPath()
+	default:
+		return nil, nil
+	}
+	tmpl, err := parseTemplate(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid path template of %s", md.GetFullyQualifiedName())
+	}
+	bs := []*binding{{httpMethod: method, tmpl: tmpl, md: md, body: rule.GetBody(), responseBody: rule.GetResponseBody()}}
+	for _, additional := range rule.GetAdditionalBindings() {
+		more, err := bindings(md, additional)
+		if err != nil {
+			return nil, err
+		}
+		bs = append(bs, more...)
+	}
+	return bs, nil
+}
+
+// Kinds of template segments.
+const (
+	literal = iota
+	single  // "*"
+	deep    // "**"
+)
+
+type segment struct {
+	kind    int
+	literal string
+	// field is the request field the segment is bound to.
+	field string
+}
+
+// template is a parsed path template of a google.api.http rule, e.g.
+// "/v1/{name=shelves/*/books/*}:publish".
+type template struct {
+	segments []segment
+	verb     string
+}
+
+func parseTemplate(path string) (*template, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, errors.Errorf("template %q must start with /", path)
+	}
+	path = path[1:]
+	t := new(template)
+	// The verb follows the last segment outside of variables.
+	depth := 0
+	for i := len(path) - 1; i >= 0; i-- {
+		switch path[i] {
+		case '}':
+			depth++
+		case '{':
+			depth--
+		case ':':
+			if depth == 0 {
+				t.verb, path = path[i+1:], path[:i]
+				i = -1
+			}
+		case '/':
+			if depth == 0 {
+				i = -1
+			}
+		}
+	}
+
+	for path != "" {
+		var part string
+		if strings.HasPrefix(path, "{") {
+			end := strings.IndexByte(path, '}')
+			if end < 0 {
+				return nil, errors.Errorf("unclosed variable in %q", path)
+			}
+			part, path = path[1:end], path[end+1:]
+			field, pattern := part, "*"
+			if eq := strings.IndexByte(part, '='); eq >= 0 {
+				field, pattern = part[:eq], part[eq+1:]
+			}
+			for _, p := range strings.Split(pattern, "/") {
+				s := parseSegment(p)
+				s.field = field
+				t.segments = append(t.segments, s)
+			}
+		} else {
+			end := strings.IndexByte(path, '/')
+			if end < 0 {
+				end = len(path)
+			}
+			part, path = path[:end], path[end:]
+			t.segments = append(t.segments, parseSegment(part))
+		}
+		if path != "" && !strings.HasPrefix(path, "/") {
+			return nil, errors.Errorf("unexpected %q after a variable", path)
+		}
+		path = strings.TrimPrefix(path, "/")
+	}
+	return t, nil
+}
+
+func parseSegment(s string) segment {
+	switch s {
+	case "*":
+		return segment{kind: single}
+	case "**":
+		return segment{kind: deep}
+	}
+	return segment{kind: literal, literal: s}
+}
+
+func (s segment) pattern() string {
+	switch s.kind {
+	case single:
+		return "*"
+	case deep:
+		return "**"
+	}
+	return s.literal
+}
+
+func (t *template) String() string {
+	var parts []string
+	for i := 0; i < len(t.segments); i++ {
+		s := t.segments[i]
+		if s.field == "" {
+			parts = append(parts, s.pattern())
+			continue
+		}
+		var pattern []string
+		for ; i < len(t.segments) && t.segments[i].field == s.field; i++ {
+			pattern = append(pattern, t.segments[i].pattern())
+		}
+		i--
+		parts = append(parts, fmt.Sprintf("{%s=%s}", s.field, strings.Join(pattern, "/")))
+	}
+	path := strings.Join(parts, "/")
+	if t.verb != "" {
+		path += ":" + t.verb
+	}
+	return path
+}
+
+// match returns values of variables of the escaped path if it matches.
+func (t *template) match(path string) (map[string]string, bool) {
+	path = strings.TrimPrefix(path, "/")
+	if t.verb != "" {
+		if !strings.HasSuffix(path, ":"+t.verb) {
+			return nil, false
+		}
+		path = strings.TrimSuffix(path, ":"+t.verb)
+	}
+	parts := strings.Split(path, "/")
+	vars := make(map[string]string)
+	j := 0
+	for i, s := range t.segments {
+		n := 1
+		if s.kind == deep {
+			n = len(parts) - j - (len(t.segments) - i - 1)
+			if n < 0 {
+				return nil, false
+			}
+		}
+		if j+n > len(parts) {
+			return nil, false
+		}
+		matched := parts[j : j+n]
+		j += n
+		if s.kind == literal && matched[0] != s.literal {
+			return nil, false
+		}
+		if s.kind == single && matched[0] == "" {
+			return nil, false
+		}
+		if s.field == "" {
+			continue
+		}
+		value := make([]string, len(matched))
+		for k, p := range matched {
+			unescaped, err := url.PathUnescape(p)
+			if err != nil {
+				return nil, false
+			}
+			value[k] = unescaped
+		}
+		if v, ok := vars[s.field]; ok {
+			vars[s.field] = v + "/" + strings.Join(value, "/")
+		} else {
+			vars[s.field] = strings.Join(value, "/")
+		}
+	}
+	if j != len(parts) {
+		return nil, false
+	}
+	return vars, true
+}
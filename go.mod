@@ -6,6 +6,7 @@ require (
 	github.com/chzyer/logex v1.1.10 // indirect
 	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e
 	github.com/chzyer/test v0.0.0-20180213035817-a1ea475d72b1 // indirect
+	github.com/golang/protobuf v1.3.2
 	github.com/jhump/protoreflect v1.5.0
 	github.com/pkg/errors v0.8.1
 	github.com/spf13/pflag v1.0.3
@@ -13,6 +14,7 @@ require (
 	golang.org/x/net v0.0.0-20190522155817-f3200d17e092 // indirect
 	golang.org/x/sys v0.0.0-20191026070338-33540a1f6037 // indirect
 	golang.org/x/tools v0.0.0-20190524140312-2c0ae7006135 // indirect
+	google.golang.org/genproto v0.0.0-20190819201941-24fa4b261c55
 	google.golang.org/grpc v1.25.1
 	honnef.co/go/tools v0.0.0-20190523083050-ea95bdfd59fc // indirect
 )
@@ -0,0 +1,145 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	checkMethod = "grpc.health.v1.Health.Check"
+	watchMethod = "grpc.health.v1.Health.Watch"
+)
+
+// Exit codes of the health command, they follow grpc_health_probe.
+const (
+	ExitServing           = 0
+	ExitConnectionFailure = 2
+	ExitRPCFailure        = 3
+	ExitUnhealthy         = 4
+)
+
+// Error is a failed health check with the process exit code.
+type Error struct {
+	Code int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// ExitCode returns the exit code of the process.
+func (e *Error) ExitCode() int {
+	return e.Code
+}
+
+// Check queries the serving status of the service, an empty service
+// stands for the whole server.
+func Check(ctx context.Context, cli client.Client, service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	resp := new(healthpb.HealthCheckResponse)
+	if err := cli.Invoke(ctx, checkMethod, &healthpb.HealthCheckRequest{Service: service}, resp); err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN, err
+	}
+	return resp.GetStatus(), nil
+}
+
+// Watch calls fn on every status change of the service until the stream
+// ends, the context is done or fn returns an error.
+func Watch(
+	ctx context.Context, cli client.Client, service string, fn func(healthpb.HealthCheckResponse_ServingStatus) error,
+) error {
+	stream, err := cli.NewStream(ctx, &grpc.StreamDesc{StreamName: "Watch", ServerStreams: true}, watchMethod)
+	if err != nil {
+		return err
+	}
+	if err = stream.SendMsg(&healthpb.HealthCheckRequest{Service: service}); err != nil {
+		return err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return err
+	}
+	for {
+		resp := new(healthpb.HealthCheckResponse)
+		if err = stream.RecvMsg(resp); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = fn(resp.GetStatus()); err != nil {
+			return err
+		}
+	}
+}
+
+// Result converts the outcome of a check to an error with an exit code,
+// nil is returned for a serving service.
+func Result(service string, st healthpb.HealthCheckResponse_ServingStatus, err error) error {
+	name := service
+	if name == "" {
+		name = "server"
+	}
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unavailable, codes.DeadlineExceeded:
+			return &Error{Code: ExitConnectionFailure, Msg: fmt.Sprintf("%s: connection failure: %v", name, err)}
+		default:
+			return &Error{Code: ExitRPCFailure, Msg: fmt.Sprintf("%s: health check failed: %v", name, err)}
+		}
+	}
+	if st != healthpb.HealthCheckResponse_SERVING {
+		return &Error{Code: ExitUnhealthy, Msg: fmt.Sprintf("%s: %s", name, st)}
+	}
+	return nil
+}
+
+// Run checks or watches the service given as the first argument.
+func Run(cfg *config.Config) error {
+	var service string
+	if len(cfg.Args) > 0 {
+		service = cfg.Args[0]
+	}
+	cli, err := client.NewClientFromConfig(cfg.Server)
+	if err != nil {
+		return &Error{Code: ExitConnectionFailure, Msg: err.Error()}
+	}
+	defer cli.Close()
+
+	if !cfg.Health.Watching {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Health.Timeout)
+		defer cancel()
+		st, err := Check(ctx, cli, service)
+		if err = Result(service, st, err); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stdout, st)
+		return nil
+	}
+
+	ctx := context.Background()
+	if cfg.Health.Watch > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Health.Watch)
+		defer cancel()
+	}
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	err = Watch(ctx, cli, service, func(st healthpb.HealthCheckResponse_ServingStatus) error {
+		last = st
+		fmt.Fprintln(os.Stdout, st)
+		return nil
+	})
+	// The last status is the result of a watch that ran for the period.
+	if ctx.Err() != nil {
+		err = nil
+	}
+	return Result(service, last, err)
+}
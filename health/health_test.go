@@ -0,0 +1,98 @@
+package health
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/client"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func startServer(t *testing.T) (*grpchealth.Server, client.Client, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	hs := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+
+	cli, err := client.NewClient(&client.ClientCfg{Addr: lis.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hs, cli, func() {
+		cli.Close()
+		srv.Stop()
+	}
+}
+
+func TestCheck(t *testing.T) {
+	hs, cli, stop := startServer(t)
+	defer stop()
+	hs.SetServingStatus("pkg.Orders", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	for _, tc := range []struct {
+		service string
+		code    int
+	}{
+		{"", ExitServing},
+		{"pkg.Orders", ExitUnhealthy},
+		{"pkg.Unknown", ExitRPCFailure},
+	} {
+		st, err := Check(context.Background(), cli, tc.service)
+		err = Result(tc.service, st, err)
+		code := ExitServing
+		if err != nil {
+			code = err.(*Error).ExitCode()
+		}
+		if code != tc.code {
+			t.Errorf("%q: got exit code %d (%v), want %d", tc.service, code, err, tc.code)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	hs, cli, stop := startServer(t)
+	defer stop()
+	hs.SetServingStatus("pkg.Orders", healthpb.HealthCheckResponse_SERVING)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var got []healthpb.HealthCheckResponse_ServingStatus
+	err := Watch(ctx, cli, "pkg.Orders", func(st healthpb.HealthCheckResponse_ServingStatus) error {
+		got = append(got, st)
+		if len(got) == 1 {
+			hs.SetServingStatus("pkg.Orders", healthpb.HealthCheckResponse_NOT_SERVING)
+			return nil
+		}
+		cancel()
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != healthpb.HealthCheckResponse_SERVING || got[1] != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("unexpected statuses %v", got)
+	}
+}
+
+func TestResultConnectionFailure(t *testing.T) {
+	cli, err := client.NewClient(&client.ClientCfg{Addr: "127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	st, err := Check(ctx, cli, "")
+	if err = Result("", st, err); err == nil || err.(*Error).ExitCode() != ExitConnectionFailure {
+		t.Errorf("got %v, want connection failure", err)
+	}
+}
@@ -0,0 +1,437 @@
+// Package jsoncheck explains why request JSON does not match a message type:
+// it reports the JSON path, the expected proto type and near-miss field names.
+package jsoncheck
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+)
+
+// Problem of a JSON value.
+type Problem struct {
+	// Path is the JSON path of the value, e.g. "items[0].sku".
+	Path    string
+	Message string
+	// Unknown is true for fields not defined by the message.
+	Unknown bool
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return p.Path + ": " + p.Message
+}
+
+// Check returns problems of the JSON request of the message type. An error
+// is returned if data is not valid JSON.
+func Check(md *desc.MessageDescriptor, data []byte) ([]Problem, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, syntaxError(data, err)
+	}
+	c := new(checker)
+	c.message("", md, v)
+	return c.problems, nil
+}
+
+// syntaxError adds the line and column to JSON syntax errors.
+func syntaxError(data []byte, err error) error {
+	serr, ok := err.(*json.SyntaxError)
+	if !ok {
+		return errors.Wrap(err, "invalid JSON")
+	}
+	line, col := 1, 1
+	for _, b := range data[:serr.Offset] {
+		if b == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	return errors.Errorf("invalid JSON at line %d, column %d: %v", line, col, err)
+}
+
+// WithoutUnknown returns the JSON with unknown fields removed.
+func WithoutUnknown(md *desc.MessageDescriptor, data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, syntaxError(data, err)
+	}
+	return json.Marshal(prune(md, v))
+}
+
+func prune(md *desc.MessageDescriptor, v interface{}) interface{} {
+	obj, ok := v.(map[string]interface{})
+	if !ok || wellKnown(md) {
+		return v
+	}
+	for k, val := range obj {
+		fd := findField(md, k)
+		if fd == nil {
+			delete(obj, k)
+			continue
+		}
+		if fd.GetMessageType() == nil || fd.IsMap() && fd.GetMapValueType().GetMessageType() == nil {
+			continue
+		}
+		switch {
+		case fd.IsMap():
+			if m, ok := val.(map[string]interface{}); ok {
+				for mk, mv := range m {
+					m[mk] = prune(fd.GetMapValueType().GetMessageType(), mv)
+				}
+			}
+		case fd.IsRepeated():
+			if items, ok := val.([]interface{}); ok {
+				for i, item := range items {
+					items[i] = prune(fd.GetMessageType(), item)
+				}
+			}
+		default:
+			obj[k] = prune(fd.GetMessageType(), val)
+		}
+	}
+	return obj
+}
+
+type checker struct {
+	problems []Problem
+}
+
+func (c *checker) addf(path, format string, a ...interface{}) {
+	c.problems = append(c.problems, Problem{Path: path, Message: fmt.Sprintf(format, a...)})
+}
+
+func (c *checker) message(path string, md *desc.MessageDescriptor, v interface{}) {
+	if v == nil {
+		return
+	}
+	if wellKnown(md) {
+		c.wellKnown(path, md, v)
+		return
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		c.addf(path, "expected object %s, got %s", md.GetFullyQualifiedName(), describe(v))
+		return
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	oneofs := make(map[string]string)
+	for _, k := range keys {
+		p := join(path, k)
+		fd := findField(md, k)
+		if fd == nil {
+			msg := fmt.Sprintf("unknown field \"%s\" in %s", k, md.GetFullyQualifiedName())
+			if s := suggest(md, k); s != "" {
+				msg += fmt.Sprintf(", did you mean %s?", s)
+			}
+			c.problems = append(c.problems, Problem{Path: p, Message: msg, Unknown: true})
+			continue
+		}
+		if od := fd.GetOneOf(); od != nil && obj[k] != nil {
+			if other, ok := oneofs[od.GetName()]; ok {
+				c.addf(p, "only one field of oneof %s may be set, %s is set too", od.GetName(), other)
+			}
+			oneofs[od.GetName()] = k
+		}
+		c.field(p, fd, obj[k])
+	}
+}
+
+func (c *checker) field(path string, fd *desc.FieldDescriptor, v interface{}) {
+	if v == nil {
+		return
+	}
+	switch {
+	case fd.IsMap():
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			c.addf(path, "expected %s, got %s", typeName(fd), describe(v))
+			return
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := fmt.Sprintf("%s[%q]", path, k)
+			if msg := checkMapKey(fd.GetMapKeyType(), k); msg != "" {
+				c.addf(p, "%s", msg)
+			}
+			c.value(p, fd.GetMapValueType(), obj[k])
+		}
+	case fd.IsRepeated():
+		items, ok := v.([]interface{})
+		if !ok {
+			c.addf(path, "expected %s, got %s", typeName(fd), describe(v))
+			return
+		}
+		for i, item := range items {
+			c.value(fmt.Sprintf("%s[%d]", path, i), fd, item)
+		}
+	default:
+		c.value(path, fd, v)
+	}
+}
+
+// value checks a single value of the field, an element of repeated fields.
+func (c *checker) value(path string, fd *desc.FieldDescriptor, v interface{}) {
+	if v == nil {
+		return
+	}
+	if md := fd.GetMessageType(); md != nil {
+		c.message(path, md, v)
+		return
+	}
+	if msg := checkScalar(fd, v); msg != "" {
+		c.addf(path, "%s", msg)
+	}
+}
+
+func (c *checker) wellKnown(path string, md *desc.MessageDescriptor, v interface{}) {
+	name := md.GetFullyQualifiedName()
+	switch strings.TrimPrefix(name, "google.protobuf.") {
+	case "Timestamp", "Duration", "FieldMask":
+		if _, ok := v.(string); !ok {
+			c.addf(path, "expected %s as a string, got %s", name, describe(v))
+		}
+	case "ListValue":
+		if _, ok := v.([]interface{}); !ok {
+			c.addf(path, "expected %s as an array, got %s", name, describe(v))
+		}
+	case "Struct", "Any", "Empty":
+		if _, ok := v.(map[string]interface{}); !ok {
+			c.addf(path, "expected %s as an object, got %s", name, describe(v))
+		}
+	case "Value":
+	default:
+		// Wrappers are represented by the wrapped value.
+		if fd := md.FindFieldByName("value"); fd != nil {
+			c.value(path, fd, v)
+		}
+	}
+}
+
+func wellKnown(md *desc.MessageDescriptor) bool {
+	return md.GetFile().GetPackage() == "google.protobuf" &&
+		!strings.HasSuffix(md.GetFile().GetName(), "descriptor.proto")
+}
+
+func checkMapKey(fd *desc.FieldDescriptor, k string) string {
+	switch fd.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return ""
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		if k == "true" || k == "false" {
+			return ""
+		}
+		return fmt.Sprintf("expected bool key, got %q", k)
+	}
+	return checkScalar(fd, k)
+}
+
+// checkScalar returns a description of the mismatch, or "" if the value is
+// accepted for the field.
+func checkScalar(fd *desc.FieldDescriptor, v interface{}) string {
+	expected := scalarName(fd)
+	mismatch := func() string {
+		return fmt.Sprintf("expected %s, got %s", expected, describe(v))
+	}
+	switch fd.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		if _, ok := v.(string); !ok {
+			return mismatch()
+		}
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		s, ok := v.(string)
+		if !ok {
+			return mismatch()
+		}
+		if !isBase64(s) {
+			return fmt.Sprintf("expected bytes as a base64 string, got %s", describe(v))
+		}
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		if _, ok := v.(bool); !ok {
+			return mismatch()
+		}
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return checkEnum(fd.GetEnumType(), v)
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT, descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		s := numberString(v)
+		if s == "NaN" || s == "Infinity" || s == "-Infinity" {
+			return ""
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return mismatch()
+		}
+		if fd.GetType() == descriptor.FieldDescriptorProto_TYPE_FLOAT && math.Abs(f) > math.MaxFloat32 {
+			return fmt.Sprintf("%s is out of range of float", s)
+		}
+	default:
+		return checkInteger(fd, expected, v)
+	}
+	return ""
+}
+
+func checkInteger(fd *desc.FieldDescriptor, expected string, v interface{}) string {
+	s := numberString(v)
+	if s == "" {
+		return fmt.Sprintf("expected %s, got %s", expected, describe(v))
+	}
+	bits := 64
+	switch fd.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32, descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		bits = 32
+	}
+	var err error
+	switch fd.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		_, err = strconv.ParseUint(s, 10, bits)
+	default:
+		_, err = strconv.ParseInt(s, 10, bits)
+	}
+	if err == nil {
+		return ""
+	}
+	if nerr, ok := err.(*strconv.NumError); ok && nerr.Err == strconv.ErrRange {
+		return fmt.Sprintf("%s is out of range of %s", s, expected)
+	}
+	if f, ferr := strconv.ParseFloat(s, 64); ferr == nil && f != math.Trunc(f) {
+		return fmt.Sprintf("expected %s, got fractional number %s", expected, s)
+	}
+	return fmt.Sprintf("expected %s, got %s", expected, describe(v))
+}
+
+func checkEnum(ed *desc.EnumDescriptor, v interface{}) string {
+	switch v := v.(type) {
+	case json.Number:
+		n, err := strconv.ParseInt(v.String(), 10, 32)
+		if err != nil {
+			return fmt.Sprintf("expected enum %s, got %s", ed.GetFullyQualifiedName(), describe(v))
+		}
+		if ed.FindValueByNumber(int32(n)) == nil {
+			return fmt.Sprintf("%d is not a value of enum %s", n, ed.GetFullyQualifiedName())
+		}
+	case string:
+		if ed.FindValueByName(v) != nil {
+			return ""
+		}
+		names := make([]string, 0, len(ed.GetValues()))
+		for _, ev := range ed.GetValues() {
+			names = append(names, ev.GetName())
+		}
+		msg := fmt.Sprintf("\"%s\" is not a value of enum %s", v, ed.GetFullyQualifiedName())
+		if s := closest(v, names); s != "" {
+			return msg + fmt.Sprintf(", did you mean %s?", s)
+		}
+		return msg + fmt.Sprintf(", expected one of %s", strings.Join(names, ", "))
+	default:
+		return fmt.Sprintf("expected enum %s, got %s", ed.GetFullyQualifiedName(), describe(v))
+	}
+	return ""
+}
+
+// numberString returns the text of a number or of a numeric string, which
+// is accepted for numbers in proto JSON.
+func numberString(v interface{}) string {
+	switch v := v.(type) {
+	case json.Number:
+		return v.String()
+	case string:
+		return v
+	}
+	return ""
+}
+
+func isBase64(s string) bool {
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		if _, err := enc.DecodeString(s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// findField finds the field by its JSON or proto name.
+func findField(md *desc.MessageDescriptor, name string) *desc.FieldDescriptor {
+	for _, fd := range md.GetFields() {
+		if fd.GetJSONName() == name || fd.GetName() == name {
+			return fd
+		}
+	}
+	return nil
+}
+
+func typeName(fd *desc.FieldDescriptor) string {
+	if fd.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", typeName(fd.GetMapKeyType()), typeName(fd.GetMapValueType()))
+	}
+	name := scalarName(fd)
+	if fd.IsRepeated() {
+		return "repeated " + name
+	}
+	return name
+}
+
+func scalarName(fd *desc.FieldDescriptor) string {
+	if md := fd.GetMessageType(); md != nil {
+		return md.GetFullyQualifiedName()
+	}
+	if ed := fd.GetEnumType(); ed != nil {
+		return "enum " + ed.GetFullyQualifiedName()
+	}
+	return strings.ToLower(strings.TrimPrefix(fd.GetType().String(), "TYPE_"))
+}
+
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		if len(v) > 32 {
+			v = v[:32] + "..."
+		}
+		return fmt.Sprintf("string %q", v)
+	case json.Number:
+		return "number " + v.String()
+	case bool:
+		return fmt.Sprintf("bool %t", v)
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "null"
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,109 @@
+package jsoncheck
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+)
+
+const testProto = `syntax = "proto3";
+package test.check;
+import "google/protobuf/timestamp.proto";
+
+enum Status { UNKNOWN = 0; NEW = 1; SHIPPED = 2; }
+
+message Item {
+  string sku = 1;
+  int32 quantity = 2;
+}
+
+message CreateOrderRequest {
+  string order_id = 1;
+  Status status = 2;
+  repeated Item items = 3;
+  map<string, int64> counters = 4;
+  google.protobuf.Timestamp created_at = 5;
+  bytes payload = 6;
+  oneof payment {
+    string card = 7;
+    string cash = 8;
+  }
+}
+`
+
+func requestType(t *testing.T) *desc.MessageDescriptor {
+	p := protoparse.Parser{
+		Accessor: protoparse.FileContentsFromMap(map[string]string{"orders.proto": testProto}),
+	}
+	fds, err := p.ParseFiles("orders.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fds[0].FindMessage("test.check.CreateOrderRequest")
+}
+
+func TestCheck(t *testing.T) {
+	md := requestType(t)
+	problems, err := Check(md, []byte(`{
+		"orderId": "1", "status": "NEW", "createdAt": "2020-01-01T00:00:00Z", "counters": {"a": "12"},
+		"items": [{"sku": "A", "quantity": 2}], "payload": "AAE=", "card": "4242"
+	}`))
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("valid request: %v %v", problems, err)
+	}
+
+	problems, err = Check(md, []byte(`{
+		"order_idd": "1", "status": "SHIPED", "createdAt": 1, "counters": {"a": 1.5},
+		"items": [{"sku": 1, "quantity": "x"}, {"quantity": 4294967296}], "payload": "%%",
+		"card": "1", "cash": "2"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	want := []string{
+		`cash: only one field of oneof payment may be set, card is set too`,
+		`counters["a"]: expected int64, got fractional number 1.5`,
+		`createdAt: expected google.protobuf.Timestamp as a string, got number 1`,
+		`items[0].quantity: expected int32, got string "x"`,
+		`items[0].sku: expected string, got number 1`,
+		`items[1].quantity: 4294967296 is out of range of int32`,
+		`order_idd: unknown field "order_idd" in test.check.CreateOrderRequest, did you mean order_id?`,
+		`payload: expected bytes as a base64 string, got string "%%"`,
+		`status: "SHIPED" is not a value of enum test.check.Status, did you mean SHIPPED?`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !problems[6].Unknown {
+		t.Error("order_idd is not reported as unknown")
+	}
+
+	if _, err = Check(md, []byte("{\n  \"orderId\": 1,,\n}")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("syntax error = %v", err)
+	}
+}
+
+func TestWithoutUnknown(t *testing.T) {
+	b, err := WithoutUnknown(requestType(t), []byte(`{"orderId": "1", "x": 1, "items": [{"sku": "A", "y": 2}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want interface{}
+	json.Unmarshal(b, &got)
+	json.Unmarshal([]byte(`{"orderId": "1", "items": [{"sku": "A"}]}`), &want)
+	if gb, wb := mustJSON(got), mustJSON(want); gb != wb {
+		t.Errorf("got %s, want %s", gb, wb)
+	}
+}
+
+func mustJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,62 @@
+package jsoncheck
+
+import (
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+)
+
+// suggest returns the field name closest to the unknown one, or "".
+func suggest(md *desc.MessageDescriptor, name string) string {
+	names := make([]string, 0, 2*len(md.GetFields()))
+	for _, fd := range md.GetFields() {
+		names = append(names, fd.GetName())
+		if fd.GetJSONName() != fd.GetName() {
+			names = append(names, fd.GetJSONName())
+		}
+	}
+	return closest(name, names)
+}
+
+// closest returns the candidate within the edit distance of a third of the
+// name length, ignoring case, or "".
+func closest(name string, candidates []string) string {
+	best, bestDist := "", len(name)/3+1
+	for _, c := range candidates {
+		if d := levenshtein(strings.ToLower(name), strings.ToLower(c)); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a ...int) int {
+	m := a[0]
+	for _, v := range a[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,112 @@
+// Package jsonfields builds request JSON from string values of dotted field
+// paths, like query parameters of the gateway and CSV cells of batch calls.
+package jsonfields
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	descpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+)
+
+// Object is a JSON object of request fields.
+type Object map[string]interface{}
+
+// Set sets the field of the dotted path, names may be proto or JSON names.
+// Repeated fields are set to all values, other fields to the last one.
+func (o Object) Set(md *desc.MessageDescriptor, path string, values ...string) error {
+	obj, fd, err := o.field(md, path)
+	if err != nil {
+		return err
+	}
+	if fd.IsRepeated() && !fd.IsMap() {
+		vals := make([]interface{}, len(values))
+		for i, v := range values {
+			vals[i] = Value(fd, v)
+		}
+		obj[fd.GetName()] = vals
+	} else {
+		obj[fd.GetName()] = Value(fd, values[len(values)-1])
+	}
+	return nil
+}
+
+// SetJSON is Set of a single value which may be JSON: an object or an array
+// sets a message, map or repeated field as it is.
+func (o Object) SetJSON(md *desc.MessageDescriptor, path, value string) error {
+	obj, fd, err := o.field(md, path)
+	if err != nil {
+		return err
+	}
+	t := strings.TrimSpace(value)
+	if !(fd.IsRepeated() || fd.GetMessageType() != nil) || !(strings.HasPrefix(t, "{") || strings.HasPrefix(t, "[")) {
+		return o.Set(md, path, value)
+	}
+	if !json.Valid([]byte(t)) {
+		return errors.Errorf("invalid JSON of field %s", fd.GetName())
+	}
+	obj[fd.GetName()] = json.RawMessage(t)
+	return nil
+}
+
+// field returns the field of the path and the object holding it, objects
+// of the messages on the path are created.
+func (o Object) field(md *desc.MessageDescriptor, path string) (Object, *desc.FieldDescriptor, error) {
+	names := strings.Split(path, ".")
+	obj := o
+	for i, name := range names {
+		fd := md.FindFieldByName(name)
+		if fd == nil {
+			fd = md.FindFieldByJSONName(name)
+		}
+		if fd == nil {
+			return nil, nil, errors.Errorf("unknown field %s in %s", strings.Join(names[:i+1], "."), md.GetFullyQualifiedName())
+		}
+		if i == len(names)-1 {
+			return obj, fd, nil
+		}
+		if fd.GetMessageType() == nil || fd.IsRepeated() {
+			return nil, nil, errors.Errorf("field %s of %s is not a message", name, md.GetFullyQualifiedName())
+		}
+		next, ok := obj[fd.GetName()].(Object)
+		if !ok {
+			next = Object{}
+			obj[fd.GetName()] = next
+		}
+		obj, md = next, fd.GetMessageType()
+	}
+	return nil, nil, errors.New("empty field path")
+}
+
+// Value converts the string to the JSON value of the field type, which is
+// left a string if it does not parse.
+func Value(fd *desc.FieldDescriptor, s string) interface{} {
+	if mt := fd.GetMessageType(); mt != nil {
+		// Wrappers are written as their values.
+		if value := mt.FindFieldByName("value"); value != nil && strings.HasPrefix(mt.GetFullyQualifiedName(), "google.protobuf.") {
+			return Value(value, s)
+		}
+		return s
+	}
+	switch fd.GetType() {
+	case descpb.FieldDescriptorProto_TYPE_BOOL:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case descpb.FieldDescriptorProto_TYPE_INT32, descpb.FieldDescriptorProto_TYPE_SINT32,
+		descpb.FieldDescriptorProto_TYPE_SFIXED32, descpb.FieldDescriptorProto_TYPE_UINT32,
+		descpb.FieldDescriptorProto_TYPE_FIXED32, descpb.FieldDescriptorProto_TYPE_FLOAT,
+		descpb.FieldDescriptorProto_TYPE_DOUBLE:
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(s)
+		}
+	case descpb.FieldDescriptorProto_TYPE_ENUM:
+		if _, err := strconv.ParseInt(s, 10, 32); err == nil {
+			return json.Number(s)
+		}
+	}
+	return s
+}
@@ -0,0 +1,88 @@
+package jsonfields
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+)
+
+const testProto = `syntax = "proto3";
+package test.fields;
+import "google/protobuf/wrappers.proto";
+
+enum Channel { UNKNOWN = 0; EMAIL = 1; }
+
+message Target { string user_id = 1; Channel channel = 2; }
+
+message Request {
+  int32 id = 1;
+  bool urgent = 2;
+  Target target = 3;
+  repeated string tags = 4;
+  google.protobuf.Int32Value limit = 5;
+  map<string, string> labels = 6;
+}
+`
+
+func request(t *testing.T) *desc.MessageDescriptor {
+	dir, err := ioutil.TempDir("", "jsonfields")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "fields.proto"), []byte(testProto), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fds, err := protoparse.Parser{ImportPaths: []string{dir}}.ParseFiles("fields.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fds[0].FindMessage("test.fields.Request")
+}
+
+func TestObject(t *testing.T) {
+	md := request(t)
+	obj := Object{}
+	for _, set := range []struct {
+		path   string
+		values []string
+	}{
+		{"id", []string{"1", "2"}},
+		{"urgent", []string{"yes"}},
+		{"target.userId", []string{"u1"}},
+		{"target.channel", []string{"1"}},
+		{"tags", []string{"a", "b"}},
+		{"limit", []string{"10"}},
+	} {
+		if err := obj.Set(md, set.path, set.values...); err != nil {
+			t.Fatalf("%s: %v", set.path, err)
+		}
+	}
+	if err := obj.SetJSON(md, "labels", `{"env": "dev"}`); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":2,"labels":{"env":"dev"},"limit":10,"tags":["a","b"],` +
+		`"target":{"channel":1,"user_id":"u1"},"urgent":"yes"}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	if err = obj.SetJSON(md, "tags", "[1,"); err == nil {
+		t.Error("invalid JSON accepted")
+	}
+	if err = obj.Set(md, "target.nope", "x"); err == nil || err.Error() != "unknown field target.nope in test.fields.Target" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err = obj.Set(md, "tags.x", "x"); err == nil {
+		t.Error("a path through a repeated field accepted")
+	}
+}
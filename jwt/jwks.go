@@ -0,0 +1,173 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	// Register hash functions used by signing algorithms.
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// JWKS is a JSON Web Key Set used for signature verification.
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// JWK is a JSON Web Key, RSA, EC and symmetric (oct) keys are supported.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// LoadJWKS reads a key set from the file.
+func LoadJWKS(path string) (*JWKS, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "jwt: failed to read JWKS")
+	}
+	jwks := new(JWKS)
+	if err = json.Unmarshal(b, jwks); err != nil {
+		return nil, errors.Wrap(err, "jwt: failed to parse JWKS")
+	}
+	return jwks, nil
+}
+
+// Verify checks the signature of the token with a key of the set. The key is
+// selected by the "kid" header, or every key is tried if it is absent.
+func (t *Token) Verify(jwks *JWKS) error {
+	alg := t.Algorithm()
+	if alg == "" || alg == "none" {
+		return errors.New("jwt: token is not signed")
+	}
+	var lastErr error = errors.New("jwt: no matching key in JWKS")
+	for _, key := range jwks.Keys {
+		if kid := t.KeyID(); kid != "" && key.Kid != kid {
+			continue
+		}
+		if key.Alg != "" && key.Alg != alg {
+			continue
+		}
+		if lastErr = key.verify(alg, t.signingInput, t.signature); lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func (k *JWK) verify(alg, input string, sig []byte) error {
+	hash, err := hashFor(alg)
+	if err != nil {
+		return err
+	}
+	switch {
+	case k.Kty == "RSA" && (alg[:2] == "RS" || alg[:2] == "PS"):
+		pub, err := k.rsaKey()
+		if err != nil {
+			return err
+		}
+		digest := sum(hash, input)
+		if alg[:2] == "PS" {
+			err = rsa.VerifyPSS(pub, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+		}
+		return errors.Wrap(err, "jwt: invalid signature")
+	case k.Kty == "EC" && alg[:2] == "ES":
+		pub, err := k.ecKey()
+		if err != nil {
+			return err
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("jwt: invalid signature length")
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, sum(hash, input), r, s) {
+			return errors.New("jwt: invalid signature")
+		}
+		return nil
+	case k.Kty == "oct" && alg[:2] == "HS":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return errors.Wrap(err, "jwt: invalid oct key")
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write([]byte(input))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errors.New("jwt: invalid signature")
+		}
+		return nil
+	}
+	return errors.Errorf("jwt: key type %s does not match algorithm %s", k.Kty, alg)
+}
+
+func hashFor(alg string) (crypto.Hash, error) {
+	if len(alg) == 5 {
+		switch alg[2:] {
+		case "256":
+			return crypto.SHA256, nil
+		case "384":
+			return crypto.SHA384, nil
+		case "512":
+			return crypto.SHA512, nil
+		}
+	}
+	return 0, errors.Errorf("jwt: unsupported algorithm %s", alg)
+}
+
+func sum(hash crypto.Hash, input string) []byte {
+	h := hash.New()
+	h.Write([]byte(input))
+	return h.Sum(nil)
+}
+
+func (k *JWK) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, errors.Wrap(err, "jwt: invalid RSA modulus")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, errors.Wrap(err, "jwt: invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+func (k *JWK) ecKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, errors.Errorf("jwt: unsupported curve %s", k.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, errors.Wrap(err, "jwt: invalid EC x coordinate")
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, errors.Wrap(err, "jwt: invalid EC y coordinate")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
@@ -0,0 +1,147 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Token is a decoded, not necessarily verified, JSON Web Token.
+type Token struct {
+	Header map[string]interface{}
+	Claims Claims
+
+	signingInput string
+	signature    []byte
+}
+
+// Claims of a token.
+type Claims map[string]interface{}
+
+// Parse decodes a compact serialized token, a "Bearer " prefix is allowed.
+func Parse(raw string) (*Token, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) > 7 && strings.EqualFold(raw[:7], "bearer ") {
+		raw = strings.TrimSpace(raw[7:])
+	}
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("jwt: token must have three parts")
+	}
+	t := &Token{signingInput: parts[0] + "." + parts[1]}
+	if err := decodeSegment(parts[0], &t.Header); err != nil {
+		return nil, errors.Wrap(err, "jwt: invalid header")
+	}
+	if err := decodeSegment(parts[1], &t.Claims); err != nil {
+		return nil, errors.Wrap(err, "jwt: invalid claims")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "jwt: invalid signature encoding")
+	}
+	t.signature = sig
+	return t, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(seg, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Algorithm returns the "alg" header.
+func (t *Token) Algorithm() string {
+	alg, _ := t.Header["alg"].(string)
+	return alg
+}
+
+// KeyID returns the "kid" header.
+func (t *Token) KeyID() string {
+	kid, _ := t.Header["kid"].(string)
+	return kid
+}
+
+func (c Claims) Subject() string {
+	return c.str("sub")
+}
+
+func (c Claims) Issuer() string {
+	return c.str("iss")
+}
+
+// ExpiresAt returns the "exp" claim, ok is false if it is absent.
+func (c Claims) ExpiresAt() (exp time.Time, ok bool) {
+	return c.time("exp")
+}
+
+// IssuedAt returns the "iat" claim, ok is false if it is absent.
+func (c Claims) IssuedAt() (iat time.Time, ok bool) {
+	return c.time("iat")
+}
+
+// Scopes returns scopes of the space separated "scope" claim or of the
+// "scp" and "scopes" arrays.
+func (c Claims) Scopes() []string {
+	if scope := c.str("scope"); scope != "" {
+		return strings.Fields(scope)
+	}
+	for _, key := range []string{"scp", "scopes"} {
+		switch v := c[key].(type) {
+		case string:
+			return strings.Fields(v)
+		case []interface{}:
+			scopes := make([]string, 0, len(v))
+			for _, s := range v {
+				if str, ok := s.(string); ok {
+					scopes = append(scopes, str)
+				}
+			}
+			return scopes
+		}
+	}
+	return nil
+}
+
+func (c Claims) str(key string) string {
+	s, _ := c[key].(string)
+	return s
+}
+
+func (c Claims) time(key string) (time.Time, bool) {
+	v, ok := c[key].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(v), 0), true
+}
+
+// Expiry describes validity of the token at the given moment.
+type Expiry int
+
+const (
+	// NoExpiry is returned for tokens without the "exp" claim.
+	NoExpiry Expiry = iota
+	Valid
+	ExpiresSoon
+	Expired
+)
+
+// CheckExpiry classifies the token, tokens expiring within the window
+// are reported as ExpiresSoon.
+func (t *Token) CheckExpiry(now time.Time, window time.Duration) Expiry {
+	exp, ok := t.Claims.ExpiresAt()
+	switch {
+	case !ok:
+		return NoExpiry
+	case !now.Before(exp):
+		return Expired
+	case now.Add(window).After(exp):
+		return ExpiresSoon
+	}
+	return Valid
+}
@@ -0,0 +1,117 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func encode(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestParseClaims(t *testing.T) {
+	exp := time.Now().Add(2 * time.Minute).Unix()
+	raw := encode(map[string]string{"alg": "none"}) + "." +
+		encode(map[string]interface{}{"sub": "42", "iss": "auth", "exp": exp, "scope": "read write"}) + "."
+
+	tok, err := Parse("Bearer " + raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Claims.Subject() != "42" || tok.Claims.Issuer() != "auth" {
+		t.Errorf("unexpected claims %v", tok.Claims)
+	}
+	if scopes := tok.Claims.Scopes(); len(scopes) != 2 || scopes[1] != "write" {
+		t.Errorf("unexpected scopes %v", scopes)
+	}
+	now := time.Now()
+	if got := tok.CheckExpiry(now, time.Minute); got != Valid {
+		t.Errorf("got %v, want Valid", got)
+	}
+	if got := tok.CheckExpiry(now, 5*time.Minute); got != ExpiresSoon {
+		t.Errorf("got %v, want ExpiresSoon", got)
+	}
+	if got := tok.CheckExpiry(now.Add(time.Hour), time.Minute); got != Expired {
+		t.Errorf("got %v, want Expired", got)
+	}
+	if err = tok.Verify(&JWKS{}); err == nil {
+		t.Error("unsigned token must not verify")
+	}
+}
+
+func TestVerifyRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := encode(map[string]string{"alg": "RS256", "kid": "k1"}) + "." + encode(map[string]string{"sub": "1"})
+	h := crypto.SHA256.New()
+	h.Write([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := Parse(input + "." + b64(sig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := &JWKS{Keys: []*JWK{{
+		Kty: "RSA", Kid: "k1",
+		N: b64(key.N.Bytes()), E: b64(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	if err = tok.Verify(jwks); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	jwks.Keys[0].Kid = "other"
+	if err = tok.Verify(jwks); err == nil {
+		t.Error("key with another kid must not be used")
+	}
+}
+
+func TestVerifyEC(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := encode(map[string]string{"alg": "ES256"}) + "." + encode(map[string]string{"sub": "1"})
+	h := crypto.SHA256.New()
+	h.Write([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(sig[32-len(rb):32], rb)
+	copy(sig[64-len(sb):], sb)
+
+	jwks := &JWKS{Keys: []*JWK{{Kty: "EC", Crv: "P-256", X: b64(key.X.Bytes()), Y: b64(key.Y.Bytes())}}}
+	tok, err := Parse(input + "." + b64(sig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tok.Verify(jwks); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	sig[0] ^= 0xff
+	if tok, err = Parse(input + "." + b64(sig)); err != nil {
+		t.Fatal(err)
+	}
+	if err = tok.Verify(jwks); err == nil {
+		t.Error("tampered signature must not verify")
+	}
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/alexej-v/grpc_cli/app"
 )
 
 func main() {
 	if err := app.Run(); err != nil {
-		log.Fatal(err)
+		log.Print(err)
+		os.Exit(app.ExitCode(err))
 	}
 }
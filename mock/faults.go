@@ -0,0 +1,415 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/proto"
+
+	gproto "github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Fault is a failure injected into calls of the mock server or the proxy.
+//
+// A faults file holds either a single fault or an array of them:
+//
+//	{
+//	  "method": "pkg.Service/Method",
+//	  "match": {"order_id": "42"},
+//	  "probability": 0.3,
+//	  "latency": "2s",
+//	  "error": {"code": "UNAVAILABLE", "message": "try again"}
+//	}
+//
+// The first fault matching a call is injected with its probability, which
+// is 1 if it is not set.
+type Fault struct {
+	Method      string                 `json:"method"`
+	Match       map[string]interface{} `json:"match"`
+	Probability float64                `json:"probability"`
+	// Latency delays the call before it is handled.
+	Latency Duration `json:"latency"`
+	// Error is returned instead of responses, or after Truncate responses.
+	Error *Error `json:"error"`
+	// Drop closes the connection of the call.
+	Drop bool `json:"drop"`
+	// Truncate ends server streams after the given number of responses.
+	Truncate *int `json:"truncate"`
+	// Corrupt sends responses that fail to decode.
+	Corrupt bool `json:"corrupt"`
+}
+
+func (f *Fault) String() string {
+	var parts []string
+	if f.Latency > 0 {
+		parts = append(parts, fmt.Sprintf("latency %s", time.Duration(f.Latency)))
+	}
+	if f.Drop {
+		parts = append(parts, "drop")
+	}
+	if f.Truncate != nil {
+		parts = append(parts, fmt.Sprintf("truncate after %d", *f.Truncate))
+	}
+	if f.Corrupt {
+		parts = append(parts, "corrupt")
+	}
+	if f.Error != nil {
+		parts = append(parts, f.Error.Code.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Faults injects faults with server interceptors. Requests are decoded with
+// the spec to match faults, it may be nil if faults have no matches.
+type Faults struct {
+	faults []*Fault
+	spec   proto.Spec
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	conns map[string]net.Conn
+}
+
+// NewFaults returns faults injected in the given order.
+func NewFaults(spec proto.Spec, faults ...*Fault) *Faults {
+	return &Faults{
+		faults: faults,
+		spec:   spec,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		conns:  make(map[string]net.Conn),
+	}
+}
+
+// LoadFaults reads faults of the file.
+func LoadFaults(path string, spec proto.Spec) (*Faults, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "mock: failed to read faults")
+	}
+	var faults []*Fault
+	if trimmed := strings.TrimSpace(string(b)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(b, &faults)
+	} else {
+		fault := new(Fault)
+		err = json.Unmarshal(b, fault)
+		faults = append(faults, fault)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "mock: failed to parse faults %s", path)
+	}
+	return NewFaults(spec, faults...), nil
+}
+
+// ServerOptions returns interceptors injecting the faults.
+func (f *Faults) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(f.unaryInterceptor),
+		grpc.StreamInterceptor(f.streamInterceptor),
+	}
+}
+
+// Listener tracks connections of the listener, so they can be dropped.
+func (f *Faults) Listener(lis net.Listener) net.Listener {
+	return &faultListener{Listener: lis, f: f}
+}
+
+type faultListener struct {
+	net.Listener
+	f *Faults
+}
+
+func (l *faultListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	fc := &faultConn{Conn: conn, f: l.f, addr: conn.RemoteAddr().String()}
+	l.f.mu.Lock()
+	l.f.conns[fc.addr] = fc
+	l.f.mu.Unlock()
+	return fc, nil
+}
+
+// faultConn forgets the connection when it is closed.
+type faultConn struct {
+	net.Conn
+	f    *Faults
+	addr string
+}
+
+func (c *faultConn) Close() error {
+	c.f.mu.Lock()
+	delete(c.f.conns, c.addr)
+	c.f.mu.Unlock()
+	return c.Conn.Close()
+}
+
+// find returns the fault to inject into the call, req is nil if the
+// request is not received yet.
+func (f *Faults) find(method string, req interface{}) *Fault {
+	var views []interface{}
+	for _, fault := range f.faults {
+		m := normalizeMethod(fault.Method)
+		if m != anyMethod && m != normalizeMethod(method) {
+			continue
+		}
+		if len(fault.Match) > 0 {
+			if views == nil {
+				views = f.requestViews(method, req)
+			}
+			matched := false
+			for _, view := range views {
+				if contains(view, map[string]interface{}(fault.Match)) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		if fault.Probability > 0 && fault.Probability < 1 {
+			f.mu.Lock()
+			skip := f.rand.Float64() >= fault.Probability
+			f.mu.Unlock()
+			if skip {
+				continue
+			}
+		}
+		log.Printf("%s: injecting fault: %s", method, fault)
+		return fault
+	}
+	return nil
+}
+
+// requestViews decodes the request with the spec if it is not a dynamic
+// message, e.g. a raw message of the proxy.
+func (f *Faults) requestViews(method string, req interface{}) []interface{} {
+	msg, ok := req.(*dynamic.Message)
+	if !ok && f.spec != nil {
+		if pm, isProto := req.(gproto.Message); isProto {
+			msg = f.decode(method, pm)
+		}
+	}
+	if msg == nil {
+		return []interface{}{}
+	}
+	views, err := requestViews(msg)
+	if err != nil {
+		return []interface{}{}
+	}
+	return views
+}
+
+func (f *Faults) decode(method string, pm gproto.Message) *dynamic.Message {
+	rpc, err := f.spec.LookupRPC(method)
+	if err != nil {
+		return nil
+	}
+	b, err := gproto.Marshal(pm)
+	if err != nil {
+		return nil
+	}
+	v, err := rpc.RequestType.New()
+	if err != nil {
+		return nil
+	}
+	msg, ok := v.(*dynamic.Message)
+	if !ok || gproto.Unmarshal(b, msg) != nil {
+		return nil
+	}
+	return msg
+}
+
+// matchesRequests reports whether faults of the method depend on requests.
+func (f *Faults) matchesRequests(method string) bool {
+	for _, fault := range f.faults {
+		m := normalizeMethod(fault.Method)
+		if (m == anyMethod || m == normalizeMethod(method)) && len(fault.Match) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// inject applies latency, drop and error faults, it returns the status of
+// the call if it must fail before responses are sent.
+func (f *Faults) inject(ctx context.Context, fault *Fault) error {
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(time.Duration(fault.Latency)):
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if fault.Drop {
+		if p, ok := peer.FromContext(ctx); ok {
+			f.mu.Lock()
+			conn := f.conns[p.Addr.String()]
+			f.mu.Unlock()
+			if conn != nil {
+				conn.Close()
+			}
+		}
+		return status.Error(codes.Unavailable, "mock: connection dropped")
+	}
+	if fault.Error != nil && fault.Truncate == nil {
+		return status.Error(fault.Error.Code, fault.Error.Message)
+	}
+	return nil
+}
+
+func (f *Faults) unaryInterceptor(
+	ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (interface{}, error) {
+	fault := f.find(info.FullMethod, req)
+	if fault == nil {
+		return handler(ctx, req)
+	}
+	if err := f.inject(ctx, fault); err != nil {
+		return nil, err
+	}
+	resp, err := handler(ctx, req)
+	if err != nil || !fault.Corrupt {
+		return resp, err
+	}
+	return corrupt(resp), nil
+}
+
+func (f *Faults) streamInterceptor(
+	srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) error {
+	fs := &faultStream{ServerStream: ss, f: f, method: info.FullMethod}
+	if !f.matchesRequests(info.FullMethod) {
+		if err := fs.decide(nil); err != nil {
+			return err
+		}
+	}
+	err := handler(srv, fs)
+	if ferr := fs.status(); ferr == errTruncated {
+		return nil
+	} else if ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+// errTruncated ends handlers of truncated streams.
+var errTruncated = errors.New("mock: stream truncated")
+
+// faultStream injects the fault decided on the first request.
+type faultStream struct {
+	grpc.ServerStream
+	f      *Faults
+	method string
+
+	// mu guards the fault against sends of another goroutine, e.g. the one
+	// forwarding responses in the proxy.
+	mu      sync.Mutex
+	decided bool
+	fault   *Fault
+	sent    int
+	// err is the status of the call, it overrides the one of the handler.
+	err error
+}
+
+func (s *faultStream) decide(req interface{}) error {
+	fault := s.f.find(s.method, req)
+	var err error
+	if fault != nil {
+		err = s.f.inject(s.Context(), fault)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.decided, s.fault, s.err = true, fault, err
+	return err
+}
+
+// status returns the status overriding the one of the handler.
+func (s *faultStream) status() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *faultStream) RecvMsg(m interface{}) error {
+	s.mu.Lock()
+	decided, err := s.decided, s.err
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = s.ServerStream.RecvMsg(m); err != nil || decided {
+		return err
+	}
+	return s.decide(m)
+}
+
+func (s *faultStream) SendMsg(m interface{}) error {
+	m, err := s.next(m)
+	if err != nil {
+		return err
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+// next returns the message to send with the fault applied, or the error
+// ending the stream.
+func (s *faultStream) next(m interface{}) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.err != nil:
+		return nil, s.err
+	case s.fault == nil:
+		return m, nil
+	case s.fault.Truncate != nil && s.sent >= *s.fault.Truncate:
+		s.err = errTruncated
+		if s.fault.Error != nil {
+			s.err = status.Error(s.fault.Error.Code, s.fault.Error.Message)
+		}
+		return nil, s.err
+	}
+	s.sent++
+	if s.fault.Corrupt {
+		return corrupt(m), nil
+	}
+	return m, nil
+}
+
+// corrupted is a message encoded with a truncated trailing field.
+type corrupted struct {
+	b []byte
+}
+
+func (c *corrupted) Reset()                   {}
+func (c *corrupted) String() string           { return "corrupted" }
+func (c *corrupted) ProtoMessage()            {}
+func (c *corrupted) Marshal() ([]byte, error) { return c.b, nil }
+
+func corrupt(m interface{}) interface{} {
+	pm, ok := m.(gproto.Message)
+	if !ok {
+		return m
+	}
+	b, err := gproto.Marshal(pm)
+	if err != nil {
+		return m
+	}
+	// Field 1 with a length prefix longer than the rest of the message.
+	return &corrupted{b: append(b, 0x0a, 0x7f)}
+}
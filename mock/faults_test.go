@@ -0,0 +1,164 @@
+package mock
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/proto"
+
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const faultsProto = `syntax = "proto3";
+package test.faults;
+
+message Request {
+  string id = 1;
+}
+
+message Response {
+  string id = 1;
+}
+
+service Faulty {
+  rpc Get(Request) returns (Response);
+  rpc Watch(Request) returns (stream Response);
+}
+`
+
+const faultsStubs = `[
+  {"method": "test.faults.Faulty/Get", "response": {"id": "ok"}},
+  {"method": "test.faults.Faulty/Watch", "responses": [{"id": "1"}, {"id": "2"}, {"id": "3"}]}
+]`
+
+func two() *int {
+	n := 2
+	return &n
+}
+
+func TestFaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "faults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "faults.proto"), []byte(faultsProto), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "faults.json"), []byte(faultsStubs), 0600); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := proto.Parse([]string{"faults.proto"}, []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stubs, err := LoadStubs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	faults := NewFaults(spec,
+		&Fault{Method: "test.faults.Faulty/Get", Match: map[string]interface{}{"id": "error"},
+			Error: &Error{Code: codes.Unavailable, Message: "try again"}},
+		&Fault{Method: "test.faults.Faulty/Get", Match: map[string]interface{}{"id": "slow"},
+			Latency: Duration(50 * time.Millisecond)},
+		&Fault{Method: "test.faults.Faulty/Get", Match: map[string]interface{}{"id": "corrupt"}, Corrupt: true},
+		&Fault{Method: "test.faults.Faulty/Get", Match: map[string]interface{}{"id": "drop"}, Drop: true},
+		&Fault{Method: "test.faults.Faulty/Get", Match: map[string]interface{}{"id": "never"}, Probability: 1e-9,
+			Error: &Error{Code: codes.Internal}},
+		&Fault{Method: "test.faults.Faulty/Watch", Match: map[string]interface{}{"id": "truncate"}, Truncate: two()},
+		&Fault{Method: "test.faults.Faulty/Watch", Match: map[string]interface{}{"id": "abort"}, Truncate: two(),
+			Error: &Error{Code: codes.Aborted, Message: "stream aborted"}},
+	)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(spec, stubs, false, faults.ServerOptions()...)
+	go srv.Serve(faults.Listener(lis))
+	defer srv.Stop()
+
+	rpc, err := spec.LookupRPC("test.faults.Faulty.Get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := func(id string) (time.Duration, error) {
+		cli, err := client.NewClient(&client.ClientCfg{Addr: lis.Addr().String()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cli.Close()
+		req, _ := rpc.RequestType.New()
+		req.(*dynamic.Message).SetFieldByName("id", id)
+		resp, _ := rpc.ResponseType.New()
+		start := time.Now()
+		err = cli.Invoke(context.Background(), rpc.FullyQualifiedName, req, resp)
+		return time.Since(start), err
+	}
+
+	if _, err = call("any"); err != nil {
+		t.Errorf("unexpected error without a fault: %v", err)
+	}
+	if _, err = call("never"); err != nil {
+		t.Errorf("unexpected error of an improbable fault: %v", err)
+	}
+	if _, err = call("error"); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable, got %v", err)
+	}
+	if d, err := call("slow"); err != nil || d < 50*time.Millisecond {
+		t.Errorf("expected a delayed response, got %v after %s", err, d)
+	}
+	if _, err = call("corrupt"); status.Code(err) != codes.Internal {
+		t.Errorf("expected Internal for a corrupted response, got %v", err)
+	}
+	if _, err = call("drop"); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable for a dropped connection, got %v", err)
+	}
+
+	watch := func(id string) (int, error) {
+		cli, err := client.NewClient(&client.ClientCfg{Addr: lis.Addr().String()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cli.Close()
+		s, err := cli.NewStream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, "test.faults.Faulty.Watch")
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := rpc.RequestType.New()
+		req.(*dynamic.Message).SetFieldByName("id", id)
+		if err = s.SendMsg(req); err != nil {
+			t.Fatal(err)
+		}
+		s.CloseSend()
+		var n int
+		for {
+			resp, _ := rpc.ResponseType.New()
+			if err = s.RecvMsg(resp); err == io.EOF {
+				return n, nil
+			} else if err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	if n, err := watch("any"); err != nil || n != 3 {
+		t.Errorf("expected 3 responses, got %d, %v", n, err)
+	}
+	if n, err := watch("truncate"); err != nil || n != 2 {
+		t.Errorf("expected 2 responses of a truncated stream, got %d, %v", n, err)
+	}
+	if n, err := watch("abort"); status.Code(err) != codes.Aborted || n != 2 {
+		t.Errorf("expected Aborted after 2 responses, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,93 @@
+package mock
+
+import (
+	"math/rand"
+
+	descpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+const (
+	// maxDepth limits nesting of generated messages, recursive types would
+	// never end otherwise.
+	maxDepth       = 3
+	maxRepeated    = 3
+	randomAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// generate returns a message filled with random values.
+func generate(md *desc.MessageDescriptor, rnd *rand.Rand, depth int) *dynamic.Message {
+	msg := dynamic.NewMessage(md)
+	if depth >= maxDepth {
+		return msg
+	}
+	for _, od := range md.GetOneOfs() {
+		choices := od.GetChoices()
+		fd := choices[rnd.Intn(len(choices))]
+		msg.SetField(fd, randomValue(fd, rnd, depth))
+	}
+	for _, fd := range md.GetFields() {
+		if fd.GetOneOf() != nil {
+			continue
+		}
+		switch {
+		case fd.IsMap():
+			for i := rnd.Intn(maxRepeated) + 1; i > 0; i-- {
+				msg.PutMapField(fd,
+					randomValue(fd.GetMapKeyType(), rnd, depth),
+					randomValue(fd.GetMapValueType(), rnd, depth))
+			}
+		case fd.IsRepeated():
+			for i := rnd.Intn(maxRepeated) + 1; i > 0; i-- {
+				msg.AddRepeatedField(fd, randomValue(fd, rnd, depth))
+			}
+		default:
+			msg.SetField(fd, randomValue(fd, rnd, depth))
+		}
+	}
+	return msg
+}
+
+// randomValue returns a single (not repeated) value of the field type.
+func randomValue(fd *desc.FieldDescriptor, rnd *rand.Rand, depth int) interface{} {
+	switch fd.GetType() {
+	case descpb.FieldDescriptorProto_TYPE_INT32,
+		descpb.FieldDescriptorProto_TYPE_SINT32,
+		descpb.FieldDescriptorProto_TYPE_SFIXED32:
+		return int32(rnd.Intn(1000))
+	case descpb.FieldDescriptorProto_TYPE_INT64,
+		descpb.FieldDescriptorProto_TYPE_SINT64,
+		descpb.FieldDescriptorProto_TYPE_SFIXED64:
+		return int64(rnd.Intn(1000000))
+	case descpb.FieldDescriptorProto_TYPE_UINT32,
+		descpb.FieldDescriptorProto_TYPE_FIXED32:
+		return uint32(rnd.Intn(1000))
+	case descpb.FieldDescriptorProto_TYPE_UINT64,
+		descpb.FieldDescriptorProto_TYPE_FIXED64:
+		return uint64(rnd.Intn(1000000))
+	case descpb.FieldDescriptorProto_TYPE_FLOAT:
+		return rnd.Float32() * 100
+	case descpb.FieldDescriptorProto_TYPE_DOUBLE:
+		return rnd.Float64() * 100
+	case descpb.FieldDescriptorProto_TYPE_BOOL:
+		return rnd.Intn(2) == 1
+	case descpb.FieldDescriptorProto_TYPE_STRING:
+		return randomString(rnd)
+	case descpb.FieldDescriptorProto_TYPE_BYTES:
+		return []byte(randomString(rnd))
+	case descpb.FieldDescriptorProto_TYPE_ENUM:
+		values := fd.GetEnumType().GetValues()
+		return values[rnd.Intn(len(values))].GetNumber()
+	default:
+		return generate(fd.GetMessageType(), rnd, depth+1)
+	}
+}
+
+func randomString(rnd *rand.Rand) string {
+	b := make([]byte, rnd.Intn(8)+4)
+	for i := range b {
+		b[i] = randomAlphabet[rnd.Intn(len(randomAlphabet))]
+	}
+	return string(b)
+}
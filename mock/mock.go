@@ -0,0 +1,240 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/config"
+	"github.com/alexej-v/grpc_cli/proto"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server answers every method of the spec services with stubbed responses,
+// empty or random messages are sent for requests without a stub.
+type Server struct {
+	stubs  *Stubs
+	random bool
+	srv    *grpc.Server
+}
+
+// NewServer registers all services of the spec on a new gRPC server.
+func NewServer(spec proto.Spec, stubs *Stubs, random bool, opts ...grpc.ServerOption) *Server {
+	s := &Server{
+		stubs:  stubs,
+		random: random,
+		srv:    grpc.NewServer(opts...),
+	}
+	for _, sd := range spec.Services() {
+		s.srv.RegisterService(s.serviceDesc(sd), s)
+	}
+	return s
+}
+
+// Serve accepts connections on the listener until Stop is called.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.srv.Serve(lis)
+}
+
+// Stop stops the server, waiting for in-flight RPCs.
+func (s *Server) Stop() {
+	s.srv.GracefulStop()
+}
+
+// Serve starts the mock server on the configured host and port, or on the
+// unix socket of the target.
+func Serve(cfg *config.Config, spec proto.Spec) error {
+	stubs, err := LoadStubs(cfg.Mock.Stubs)
+	if err != nil {
+		return err
+	}
+	network, addr := "tcp", cfg.Server.Address()
+	if sock, ok := client.UnixSocket(addr); ok {
+		network, addr = "unix", sock
+	}
+	lis, err := net.Listen(network, addr)
+	if err != nil {
+		return errors.Wrap(err, "mock: failed to listen")
+	}
+	var opts []grpc.ServerOption
+	if cfg.Faults != "" {
+		faults, err := LoadFaults(cfg.Faults, spec)
+		if err != nil {
+			return err
+		}
+		lis, opts = faults.Listener(lis), faults.ServerOptions()
+	}
+	log.Printf("mock server is listening on %s", lis.Addr())
+	return NewServer(spec, stubs, cfg.Mock.Random, opts...).Serve(lis)
+}
+
+func (s *Server) serviceDesc(sd *desc.ServiceDescriptor) *grpc.ServiceDesc {
+	gsd := &grpc.ServiceDesc{
+		ServiceName: sd.GetFullyQualifiedName(),
+		// Any handler passes the HandlerType check of RegisterService.
+		HandlerType: (*interface{})(nil),
+		Metadata:    sd.GetFile().GetName(),
+	}
+	for _, md := range sd.GetMethods() {
+		if !md.IsClientStreaming() && !md.IsServerStreaming() {
+			gsd.Methods = append(gsd.Methods, grpc.MethodDesc{
+				MethodName: md.GetName(),
+				Handler:    s.unaryHandler(md),
+			})
+			continue
+		}
+		gsd.Streams = append(gsd.Streams, grpc.StreamDesc{
+			StreamName:    md.GetName(),
+			Handler:       s.streamHandler(md),
+			ServerStreams: md.IsServerStreaming(),
+			ClientStreams: md.IsClientStreaming(),
+		})
+	}
+	return gsd
+}
+
+func (s *Server) unaryHandler(md *desc.MethodDescriptor) func(
+	interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor,
+) (interface{}, error) {
+	return func(
+		_ interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor,
+	) (interface{}, error) {
+		req := dynamic.NewMessage(md.GetInputType())
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			resps, err := s.respond(ctx, md, req.(*dynamic.Message))
+			if err != nil {
+				return nil, err
+			}
+			return resps[0], nil
+		}
+		if interceptor == nil {
+			return handler(ctx, req)
+		}
+		return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: s, FullMethod: fullMethod(md)}, handler)
+	}
+}
+
+func (s *Server) streamHandler(md *desc.MethodDescriptor) grpc.StreamHandler {
+	return func(_ interface{}, stream grpc.ServerStream) error {
+		switch {
+		case md.IsClientStreaming() && md.IsServerStreaming():
+			for {
+				req := dynamic.NewMessage(md.GetInputType())
+				if err := stream.RecvMsg(req); err == io.EOF {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				if err := s.send(stream, md, req); err != nil {
+					return err
+				}
+			}
+		case md.IsClientStreaming():
+			// The last received message is used for stub matching.
+			var last *dynamic.Message
+			for {
+				req := dynamic.NewMessage(md.GetInputType())
+				if err := stream.RecvMsg(req); err == io.EOF {
+					break
+				} else if err != nil {
+					return err
+				}
+				last = req
+			}
+			if last == nil {
+				last = dynamic.NewMessage(md.GetInputType())
+			}
+			resps, err := s.respond(stream.Context(), md, last)
+			if err != nil {
+				return err
+			}
+			return stream.SendMsg(resps[0])
+		default:
+			req := dynamic.NewMessage(md.GetInputType())
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return s.send(stream, md, req)
+		}
+	}
+}
+
+func (s *Server) send(stream grpc.ServerStream, md *desc.MethodDescriptor, req *dynamic.Message) error {
+	resps, err := s.respond(stream.Context(), md, req)
+	if err != nil {
+		return err
+	}
+	for _, resp := range resps {
+		if err = stream.SendMsg(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// respond returns at least one response for the request or a status error.
+func (s *Server) respond(ctx context.Context, md *desc.MethodDescriptor, req *dynamic.Message) ([]*dynamic.Message, error) {
+	method := fullMethod(md)
+	stub, err := s.stubs.Find(method, req)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if stub == nil {
+		log.Printf("%s: no stub found, generating response", method)
+		return []*dynamic.Message{s.generate(md.GetOutputType())}, nil
+	}
+
+	if stub.Delay > 0 {
+		select {
+		case <-time.After(time.Duration(stub.Delay)):
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if stub.Error != nil {
+		log.Printf("%s: responding with %s", method, stub.Error.Code)
+		return nil, status.Error(stub.Error.Code, stub.Error.Message)
+	}
+
+	bodies := stub.Responses
+	if len(stub.Response) > 0 {
+		bodies = append([]json.RawMessage{stub.Response}, bodies...)
+	}
+	if len(bodies) == 0 {
+		return []*dynamic.Message{dynamic.NewMessage(md.GetOutputType())}, nil
+	}
+	resps := make([]*dynamic.Message, len(bodies))
+	for i, body := range bodies {
+		resps[i] = dynamic.NewMessage(md.GetOutputType())
+		if err = resps[i].UnmarshalJSON(body); err != nil {
+			return nil, status.Errorf(codes.Internal, "mock: invalid stub response of %s: %v", method, err)
+		}
+	}
+	log.Printf("%s: responding with stub", method)
+	return resps, nil
+}
+
+func (s *Server) generate(md *desc.MessageDescriptor) *dynamic.Message {
+	if !s.random {
+		return dynamic.NewMessage(md)
+	}
+	return generate(md, rand.New(rand.NewSource(time.Now().UnixNano())), 0)
+}
+
+func fullMethod(md *desc.MethodDescriptor) string {
+	return "/" + md.GetService().GetFullyQualifiedName() + "/" + md.GetName()
+}
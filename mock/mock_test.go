@@ -0,0 +1,159 @@
+package mock
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/proto"
+
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testProto = `syntax = "proto3";
+package test.mock;
+
+message GetOrderRequest {
+  string order_id = 1;
+}
+
+message Order {
+  string order_id = 1;
+  string status = 2;
+  repeated string items = 3;
+}
+
+service Orders {
+  rpc GetOrder(GetOrderRequest) returns (Order);
+}
+`
+
+const testStubs = `[
+  {"method": "test.mock.Orders/GetOrder", "match": {"order_id": "1"}, "response": {"order_id": "1", "status": "DONE"}},
+  {"method": "test.mock.Orders/GetOrder", "match": {"order_id": "404"}, "error": {"code": "NOT_FOUND", "message": "no order"}},
+  {"method": "test.mock.Orders.GetOrder", "response": {"status": "NEW"}}
+]`
+
+// loadTestSpec writes the test proto and stubs to a temporary directory,
+// removed by the returned function.
+func loadTestSpec(t *testing.T) (proto.Spec, *Stubs, func()) {
+	dir, err := ioutil.TempDir("", "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "orders.proto"), []byte(testProto), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "orders.json"), []byte(testStubs), 0600); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := proto.Parse([]string{"orders.proto"}, []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stubs, err := LoadStubs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec, stubs, func() { os.RemoveAll(dir) }
+}
+
+// startServer starts a mock server and a client of it.
+func startServer(t *testing.T) (client.Client, proto.Spec, func()) {
+	spec, stubs, cleanup := loadTestSpec(t)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(spec, stubs, false)
+	go srv.Serve(lis)
+
+	cli, err := client.NewClient(&client.ClientCfg{Addr: lis.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cli, spec, func() {
+		srv.Stop()
+		cleanup()
+	}
+}
+
+func getOrder(t *testing.T, cli client.Client, spec proto.Spec, orderID string) (*dynamic.Message, error) {
+	rpc, err := spec.RPC("test.mock", "Orders", "GetOrder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := rpc.RequestType.New()
+	req.(*dynamic.Message).SetFieldByName("order_id", orderID)
+	resp, _ := rpc.ResponseType.New()
+	err = cli.Invoke(context.Background(), rpc.FullyQualifiedName, req, resp)
+	return resp.(*dynamic.Message), err
+}
+
+func TestServerStubs(t *testing.T) {
+	cli, spec, stop := startServer(t)
+	defer stop()
+
+	resp, err := getOrder(t, cli, spec, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetFieldByName("status"); got != "DONE" {
+		t.Errorf("matched stub: got status %q, want DONE", got)
+	}
+
+	resp, err = getOrder(t, cli, spec, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetFieldByName("status"); got != "NEW" {
+		t.Errorf("default stub: got status %q, want NEW", got)
+	}
+
+	_, err = getOrder(t, cli, spec, "404")
+	if st := status.Convert(err); st.Code() != codes.NotFound || st.Message() != "no order" {
+		t.Errorf("error stub: got %v, want NotFound", err)
+	}
+}
+
+func TestContains(t *testing.T) {
+	actual := map[string]interface{}{
+		"id":    "123",
+		"count": float64(2),
+		"items": []interface{}{map[string]interface{}{"sku": "a", "qty": float64(1)}},
+	}
+	for _, tc := range []struct {
+		expected interface{}
+		want     bool
+	}{
+		{map[string]interface{}{"id": "123"}, true},
+		{map[string]interface{}{"id": float64(123)}, true},
+		{map[string]interface{}{"count": float64(3)}, false},
+		{map[string]interface{}{"items": []interface{}{map[string]interface{}{"sku": "a"}}}, true},
+		{map[string]interface{}{"items": []interface{}{}}, false},
+		{map[string]interface{}{"missing": "x"}, false},
+	} {
+		if got := contains(actual, tc.expected); got != tc.want {
+			t.Errorf("contains(%v) = %v, want %v", tc.expected, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeMethod(t *testing.T) {
+	for in, want := range map[string]string{
+		"/pkg.Svc/Method":    "pkg.Svc/Method",
+		"pkg.Svc/Method":     "pkg.Svc/Method",
+		"pkg.sub.Svc.Method": "pkg.sub.Svc/Method",
+		"*":                  "*",
+	} {
+		if got := normalizeMethod(in); got != want {
+			t.Errorf("normalizeMethod(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
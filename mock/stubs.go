@@ -0,0 +1,212 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+const anyMethod = "*"
+
+// Stub describes a canned answer of the mock server.
+//
+// A stub file holds either a single stub or an array of them:
+//
+//	{
+//	  "method": "pkg.Service/Method",
+//	  "match": {"order_id": "42"},
+//	  "response": {"status": "DONE"},
+//	  "delay": "200ms"
+//	}
+//
+// Stubs with a "match" are tried first, the first stub without one is used
+// as the method default. Method "*" matches every method.
+type Stub struct {
+	Method string                 `json:"method"`
+	Match  map[string]interface{} `json:"match"`
+	// Response is sent for unary and client streaming methods,
+	// Responses are sent one by one for server streaming methods.
+	Response  json.RawMessage   `json:"response"`
+	Responses []json.RawMessage `json:"responses"`
+	Error     *Error            `json:"error"`
+	Delay     Duration          `json:"delay"`
+}
+
+// Error is a status returned instead of a response.
+type Error struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message"`
+}
+
+// Duration is a time.Duration decoded from strings like "1.5s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Stubs is a set of stubs indexed by method name.
+type Stubs struct {
+	byMethod map[string][]*Stub
+}
+
+// LoadStubs reads every *.json file of the dir.
+func LoadStubs(dir string) (*Stubs, error) {
+	stubs := &Stubs{byMethod: make(map[string][]*Stub)}
+	if dir == "" {
+		return stubs, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "mock: failed to list stubs")
+	}
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "mock: failed to read stub %s", file)
+		}
+		parsed, err := parseStubs(b)
+		if err != nil {
+			return nil, errors.Wrapf(err, "mock: failed to parse stub %s", file)
+		}
+		stubs.Add(parsed...)
+	}
+	return stubs, nil
+}
+
+func parseStubs(b []byte) ([]*Stub, error) {
+	var stubs []*Stub
+	if trimmed := strings.TrimSpace(string(b)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(b, &stubs); err != nil {
+			return nil, err
+		}
+		return stubs, nil
+	}
+	stub := new(Stub)
+	if err := json.Unmarshal(b, stub); err != nil {
+		return nil, err
+	}
+	return append(stubs, stub), nil
+}
+
+// Add registers stubs, keeping their order.
+func (s *Stubs) Add(stubs ...*Stub) {
+	for _, stub := range stubs {
+		method := normalizeMethod(stub.Method)
+		s.byMethod[method] = append(s.byMethod[method], stub)
+	}
+}
+
+// Find returns the stub matching the request of the method or nil.
+func (s *Stubs) Find(method string, req *dynamic.Message) (*Stub, error) {
+	var candidates []*Stub
+	candidates = append(candidates, s.byMethod[normalizeMethod(method)]...)
+	candidates = append(candidates, s.byMethod[anyMethod]...)
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	views, err := requestViews(req)
+	if err != nil {
+		return nil, err
+	}
+	var fallback *Stub
+	for _, stub := range candidates {
+		if len(stub.Match) == 0 {
+			if fallback == nil {
+				fallback = stub
+			}
+			continue
+		}
+		for _, view := range views {
+			if contains(view, map[string]interface{}(stub.Match)) {
+				return stub, nil
+			}
+		}
+	}
+	return fallback, nil
+}
+
+// requestViews returns the request decoded as generic JSON with both
+// original proto field names and lowerCamelCase JSON names.
+func requestViews(req *dynamic.Message) ([]interface{}, error) {
+	marshalers := []*jsonpb.Marshaler{
+		{OrigName: true, EmitDefaults: true},
+		{EmitDefaults: true},
+	}
+	views := make([]interface{}, 0, len(marshalers))
+	for _, m := range marshalers {
+		b, err := req.MarshalJSONPB(m)
+		if err != nil {
+			return nil, errors.Wrap(err, "mock: failed to marshal request")
+		}
+		var view interface{}
+		if err = json.Unmarshal(b, &view); err != nil {
+			return nil, errors.Wrap(err, "mock: failed to decode request")
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// contains reports whether every field of expected is present in actual.
+func contains(actual, expected interface{}) bool {
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range exp {
+			if !contains(act[k], v) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok || len(act) != len(exp) {
+			return false
+		}
+		for i := range exp {
+			if !contains(act[i], exp[i]) {
+				return false
+			}
+		}
+		return true
+	case nil:
+		return actual == nil
+	default:
+		// 64-bit integers are encoded as JSON strings, so compare text forms.
+		return actual != nil && fmt.Sprint(actual) == fmt.Sprint(expected)
+	}
+}
+
+// normalizeMethod converts "/pkg.Service/Method" and "pkg.Service.Method"
+// to "pkg.Service/Method".
+func normalizeMethod(method string) string {
+	method = strings.TrimPrefix(method, "/")
+	if method == anyMethod || strings.Contains(method, "/") {
+		return method
+	}
+	if i := strings.LastIndex(method, "."); i > 0 {
+		return method[:i] + "/" + method[i+1:]
+	}
+	return method
+}
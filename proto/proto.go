@@ -2,10 +2,15 @@ package proto
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
+	"strings"
+
 	"github.com/alexej-v/grpc_cli/config"
 	"github.com/alexej-v/grpc_cli/grpc"
 
+	"github.com/golang/protobuf/proto"
+	descpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/desc/protoparse"
 	"github.com/jhump/protoreflect/dynamic"
@@ -25,6 +30,8 @@ type Spec interface {
 	ServiceNames(pkgName string) (svcNames []string, err error)
 	RPCs(pkgName, svcName string) ([]*grpc.RPC, error)
 	RPC(pkgName, svcName, rpcName string) (*grpc.RPC, error)
+	LookupRPC(fqrn string) (*grpc.RPC, error)
+	Services() []*desc.ServiceDescriptor
 	Messages(fqn string)
 	Describe(cfg *config.Config)
 }
@@ -46,7 +53,34 @@ func Parse(filePath []string, importPath []string) (*spec, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "proto: failed to parse proto files")
 	}
+	return newSpec(descriptors), nil
+}
+
+// ParseProtoset builds a spec from compiled FileDescriptorSet files,
+// e.g. produced by `protoc --descriptor_set_out --include_imports`.
+func ParseProtoset(protosets []string) (*spec, error) {
+	var descriptors []*desc.FileDescriptor
+	for _, protoset := range protosets {
+		b, err := ioutil.ReadFile(protoset)
+		if err != nil {
+			return nil, errors.Wrapf(err, "proto: failed to read protoset %s", protoset)
+		}
+		var fds descpb.FileDescriptorSet
+		if err = proto.Unmarshal(b, &fds); err != nil {
+			return nil, errors.Wrapf(err, "proto: failed to unmarshal protoset %s", protoset)
+		}
+		files, err := desc.CreateFileDescriptorsFromSet(&fds)
+		if err != nil {
+			return nil, errors.Wrapf(err, "proto: failed to load protoset %s", protoset)
+		}
+		for _, fd := range fds.GetFile() {
+			descriptors = append(descriptors, files[fd.GetName()])
+		}
+	}
+	return newSpec(descriptors), nil
+}
 
+func newSpec(descriptors []*desc.FileDescriptor) *spec {
 	pkgNames := make(map[string]struct{})
 	svcDescs := make(map[string][]*desc.ServiceDescriptor)
 	rpcDescs := make(map[string][]*desc.MethodDescriptor)
@@ -67,7 +101,7 @@ func Parse(filePath []string, importPath []string) (*spec, error) {
 		svcDescs: svcDescs,
 		rpcDescs: rpcDescs,
 		msgDescs: msgDescs,
-	}, nil
+	}
 }
 
 func (s *spec) PackageNames() (pkgNames []string) {
@@ -168,6 +202,36 @@ func (s *spec) RPC(pkgName, svcName, rpcName string) (*grpc.RPC, error) {
 	return nil, ErrRPCUnknown
 }
 
+// LookupRPC returns the RPC by its fully qualified name,
+// e.g. "pkg.Service.Method" or "/pkg.Service/Method".
+func (s *spec) LookupRPC(fqrn string) (*grpc.RPC, error) {
+	fqrn = strings.Replace(strings.TrimPrefix(fqrn, "/"), "/", ".", -1)
+	parts := strings.Split(fqrn, ".")
+	if len(parts) < 2 {
+		return nil, ErrRPCUnknown
+	}
+	pkgName := strings.Join(parts[:len(parts)-2], ".")
+	return s.RPC(pkgName, parts[len(parts)-2], parts[len(parts)-1])
+}
+
+// FindRPC returns the RPC by a fully qualified name like "pkg.Service/Method",
+// or by a method name of the given package and service.
+func FindRPC(s Spec, pkgName, svcName, name string) (*grpc.RPC, error) {
+	if strings.ContainsAny(name, "./") {
+		return s.LookupRPC(name)
+	}
+	return s.RPC(pkgName, svcName, name)
+}
+
+// Services returns descriptors of all services known to the spec.
+func (s *spec) Services() []*desc.ServiceDescriptor {
+	svcs := make([]*desc.ServiceDescriptor, 0, len(s.svcDescs))
+	for _, descs := range s.svcDescs {
+		svcs = append(svcs, descs...)
+	}
+	return svcs
+}
+
 func (s *spec) Messages(fqn string) {
 	log.Printf("%+v", s.msgDescs[fqn])
 }
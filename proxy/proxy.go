@@ -0,0 +1,337 @@
+// Package proxy forwards gRPC calls to an upstream server and logs them.
+//
+// Messages are forwarded as they are received, so methods missing from the
+// loaded protos are proxied too; known messages are decoded to JSON for
+// logging and recording.
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/config"
+	"github.com/alexej-v/grpc_cli/mock"
+	"github.com/alexej-v/grpc_cli/proto"
+	"github.com/alexej-v/grpc_cli/record"
+	"github.com/alexej-v/grpc_cli/redact"
+
+	gproto "github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Options of the proxy, all are optional.
+type Options struct {
+	// Out receives the call log, nothing is logged if it is nil.
+	Out      io.Writer
+	Recorder *record.Recorder
+	Redactor *redact.Redactor
+	// Upstream is the target written to records.
+	Upstream string
+}
+
+// Proxy is a gRPC server forwarding every call to the client.
+type Proxy struct {
+	cli   client.Client
+	spec  proto.Spec
+	opts  Options
+	srv   *grpc.Server
+	calls int64
+	mu    sync.Mutex
+}
+
+// New returns a proxy calling the client, messages are decoded with the
+// spec.
+func New(spec proto.Spec, cli client.Client, opts Options, srvOpts ...grpc.ServerOption) *Proxy {
+	if opts.Redactor == nil {
+		opts.Redactor = redact.New(redact.DefaultPatterns)
+	}
+	p := &Proxy{cli: cli, spec: spec, opts: opts}
+	p.srv = grpc.NewServer(append(srvOpts, grpc.UnknownServiceHandler(p.handle))...)
+	return p
+}
+
+// Serve accepts connections on the listener until Stop is called.
+func (p *Proxy) Serve(lis net.Listener) error {
+	return p.srv.Serve(lis)
+}
+
+// Stop stops the proxy, waiting for in-flight calls.
+func (p *Proxy) Stop() {
+	p.srv.GracefulStop()
+}
+
+// Serve starts the proxy on the listen address, calls are forwarded to the
+// upstream with the server settings of the config.
+func Serve(cfg *config.Config, spec proto.Spec) error {
+	if cfg.Proxy.Upstream == "" {
+		return errors.New("proxy: --upstream is required")
+	}
+	srv := *cfg.Server
+	srv.Target = cfg.Proxy.Upstream
+	// Calls are forwarded once, the callers retry them: retries here would
+	// hide failures of the upstream and injected faults.
+	srv.Retry = config.Retry{}
+	cli, err := client.NewClientFromConfig(&srv)
+	if err != nil {
+		return errors.Wrap(err, "proxy: failed to create new client")
+	}
+	defer cli.Close()
+
+	patterns := cfg.Redact
+	if len(patterns) == 0 {
+		patterns = redact.DefaultPatterns
+	}
+	opts := Options{Out: os.Stdout, Redactor: redact.New(patterns), Upstream: cfg.Proxy.Upstream}
+	if cfg.Proxy.Record != "" {
+		if opts.Recorder, err = record.Open(cfg.Proxy.Record); err != nil {
+			return err
+		}
+		defer opts.Recorder.Close()
+	}
+
+	network, addr := "tcp", cfg.Listen
+	if sock, ok := client.UnixSocket(addr); ok {
+		network, addr = "unix", sock
+	}
+	lis, err := net.Listen(network, addr)
+	if err != nil {
+		return errors.Wrap(err, "proxy: failed to listen")
+	}
+	var srvOpts []grpc.ServerOption
+	if cfg.Faults != "" {
+		faults, err := mock.LoadFaults(cfg.Faults, spec)
+		if err != nil {
+			return err
+		}
+		lis, srvOpts = faults.Listener(lis), faults.ServerOptions()
+	}
+	log.Printf("proxy is listening on %s, forwarding to %s", lis.Addr(), cfg.Proxy.Upstream)
+	return New(spec, cli, opts, srvOpts...).Serve(lis)
+}
+
+// frame is a message forwarded without decoding, it is marshaled as is by
+// the proto codec.
+type frame struct {
+	payload []byte
+}
+
+func (f *frame) Reset()         { f.payload = nil }
+func (f *frame) String() string { return fmt.Sprintf("%d bytes", len(f.payload)) }
+func (f *frame) ProtoMessage()  {}
+
+func (f *frame) Marshal() ([]byte, error) {
+	return f.payload, nil
+}
+
+func (f *frame) Unmarshal(b []byte) error {
+	f.payload = append([]byte(nil), b...)
+	return nil
+}
+
+// call is the log of a proxied call.
+type call struct {
+	p      *Proxy
+	id     int64
+	method string
+	start  time.Time
+
+	// mu guards messages appended by both directions of the call.
+	mu        sync.Mutex
+	requests  []json.RawMessage
+	responses []json.RawMessage
+}
+
+func (p *Proxy) handle(_ interface{}, ss grpc.ServerStream) error {
+	method, ok := grpc.MethodFromServerStream(ss)
+	if !ok {
+		return status.Error(codes.Internal, "proxy: unknown method")
+	}
+	c := &call{p: p, id: atomic.AddInt64(&p.calls, 1), method: method, start: time.Now()}
+	md, _ := metadata.FromIncomingContext(ss.Context())
+	md = outgoingMetadata(md)
+	c.logf("%s metadata: %s", method, c.metadata(md))
+
+	fqrn := strings.Replace(strings.TrimPrefix(method, "/"), "/", ".", 1)
+	desc := &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+	if rpc, err := p.spec.LookupRPC(fqrn); err == nil {
+		desc.ServerStreams, desc.ClientStreams = rpc.IsServerStreaming, rpc.IsClientStreaming
+	}
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(ss.Context(), md))
+	defer cancel()
+	cs, err := p.cli.NewStream(ctx, desc, fqrn)
+	if err != nil {
+		return c.finish(md, nil, nil, err)
+	}
+
+	// Requests are forwarded until the client closes its side.
+	go func() {
+		for {
+			f := new(frame)
+			if err := ss.RecvMsg(f); err != nil {
+				if err == io.EOF {
+					cs.CloseSend()
+				} else {
+					cancel()
+				}
+				return
+			}
+			c.message(fqrn, "request", f, true)
+			if err := cs.SendMsg(f); err != nil {
+				return
+			}
+		}
+	}()
+
+	headerSent := false
+	for {
+		f := new(frame)
+		err = cs.RecvMsg(f)
+		if !headerSent {
+			if header, herr := cs.Header(); herr == nil && header != nil {
+				c.logf("header: %s", c.metadata(header))
+				ss.SendHeader(header)
+			}
+			headerSent = true
+		}
+		if err != nil {
+			trailer := cs.Trailer()
+			ss.SetTrailer(trailer)
+			header, _ := cs.Header()
+			if err == io.EOF {
+				err = nil
+			}
+			return c.finish(md, header, trailer, err)
+		}
+		c.message(fqrn, "response", f, false)
+		if err = ss.SendMsg(f); err != nil {
+			return err
+		}
+	}
+}
+
+// outgoingMetadata drops pseudo and transport headers of incoming metadata.
+func outgoingMetadata(md metadata.MD) metadata.MD {
+	out := metadata.MD{}
+	for k, vals := range md {
+		if strings.HasPrefix(k, ":") || k == "content-type" || k == "user-agent" || k == "te" {
+			continue
+		}
+		out[k] = vals
+	}
+	return out
+}
+
+func (c *call) logf(format string, a ...interface{}) {
+	if c.p.opts.Out == nil {
+		return
+	}
+	c.p.mu.Lock()
+	defer c.p.mu.Unlock()
+	fmt.Fprintf(c.p.opts.Out, "[%d] %s\n", c.id, fmt.Sprintf(format, a...))
+}
+
+func (c *call) metadata(md metadata.MD) string {
+	b, _ := json.Marshal(c.p.opts.Redactor.MD(md))
+	return string(b)
+}
+
+// message logs the request or response decoded with the spec, messages of
+// unknown methods are logged by size.
+func (c *call) message(fqrn, kind string, f *frame, request bool) {
+	js := c.p.decode(fqrn, f.payload, request)
+	c.logf("%s: %s", kind, js)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if request {
+		c.requests = append(c.requests, js)
+	} else {
+		c.responses = append(c.responses, js)
+	}
+}
+
+func (p *Proxy) decode(fqrn string, payload []byte, request bool) json.RawMessage {
+	unknown := json.RawMessage(fmt.Sprintf(`{"@bytes":%d}`, len(payload)))
+	rpc, err := p.spec.LookupRPC(fqrn)
+	if err != nil {
+		return unknown
+	}
+	typ := rpc.ResponseType
+	if request {
+		typ = rpc.RequestType
+	}
+	v, err := typ.New()
+	if err != nil {
+		return unknown
+	}
+	msg, ok := v.(gproto.Message)
+	if !ok || gproto.Unmarshal(payload, msg) != nil {
+		return unknown
+	}
+	js, err := json.Marshal(msg)
+	if err != nil {
+		return unknown
+	}
+	return js
+}
+
+// finish logs and records the status of the call and returns err.
+func (c *call) finish(md, header, trailer metadata.MD, err error) error {
+	st := status.Convert(err)
+	duration := time.Since(c.start)
+	if len(trailer) > 0 {
+		c.logf("%s in %s, trailer: %s", st.Code(), duration, c.metadata(trailer))
+	} else {
+		c.logf("%s in %s", st.Code(), duration)
+	}
+	if err != nil {
+		c.logf("error: %s", st.Message())
+	}
+	if c.p.opts.Recorder == nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	redactor := c.p.opts.Redactor
+	rec := &record.Record{
+		Time:       c.start,
+		Target:     c.p.opts.Upstream,
+		Method:     strings.Replace(strings.TrimPrefix(c.method, "/"), "/", ".", 1),
+		Metadata:   redactor.MD(md),
+		Request:    single(c.requests),
+		Response:   single(c.responses),
+		Status:     record.Status{Code: st.Code(), Message: st.Message()},
+		Header:     redactor.MD(header),
+		Trailer:    redactor.MD(trailer),
+		DurationMs: duration.Seconds() * 1000,
+	}
+	if werr := c.p.opts.Recorder.Write(rec); werr != nil {
+		log.Printf("proxy: %v", werr)
+	}
+	return err
+}
+
+// single returns the only message, or a JSON array of streamed messages.
+func single(msgs []json.RawMessage) json.RawMessage {
+	switch len(msgs) {
+	case 0:
+		return nil
+	case 1:
+		return msgs[0]
+	}
+	b, _ := json.Marshal(msgs)
+	return b
+}
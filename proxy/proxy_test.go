@@ -0,0 +1,254 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexej-v/grpc_cli/client"
+	"github.com/alexej-v/grpc_cli/mock"
+	"github.com/alexej-v/grpc_cli/proto"
+	"github.com/alexej-v/grpc_cli/record"
+
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testProto = `syntax = "proto3";
+package test.proxy;
+
+message GetOrderRequest {
+  string order_id = 1;
+}
+
+message Order {
+  string order_id = 1;
+  string status = 2;
+}
+
+service Orders {
+  rpc GetOrder(GetOrderRequest) returns (Order);
+  rpc WatchOrder(GetOrderRequest) returns (stream Order);
+}
+`
+
+const testStubs = `[
+  {"method": "test.proxy.Orders/GetOrder", "match": {"order_id": "404"}, "error": {"code": "NOT_FOUND", "message": "no order"}},
+  {"method": "test.proxy.Orders/GetOrder", "response": {"order_id": "1", "status": "DONE"}},
+  {"method": "test.proxy.Orders/WatchOrder", "responses": [{"status": "NEW"}, {"status": "DONE"}]}
+]`
+
+func listen(t *testing.T) net.Listener {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lis
+}
+
+// startUpstream starts a mock server of the test proto and stubs, its
+// directory and the server are removed by the returned function.
+func startUpstream(t *testing.T) (proto.Spec, string, net.Addr, func()) {
+	dir, err := ioutil.TempDir("", "proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "orders.proto"), []byte(testProto), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "orders.json"), []byte(testStubs), 0600); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := proto.Parse([]string{"orders.proto"}, []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stubs, err := mock.LoadStubs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := listen(t)
+	upstream := mock.NewServer(spec, stubs, false)
+	go upstream.Serve(lis)
+	return spec, dir, lis.Addr(), func() {
+		upstream.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	spec, dir, upstreamAddr, cleanup := startUpstream(t)
+	defer cleanup()
+
+	upstreamCli, err := client.NewClient(&client.ClientCfg{Addr: upstreamAddr.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstreamCli.Close()
+	recorder, err := record.Open(filepath.Join(dir, "calls.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	proxyLis := listen(t)
+	p := New(spec, upstreamCli, Options{Out: &out, Recorder: recorder, Upstream: upstreamAddr.String()})
+	go p.Serve(proxyLis)
+
+	cli, err := client.NewClient(&client.ClientCfg{Addr: proxyLis.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret", "x-id", "42")
+
+	rpc, err := spec.LookupRPC("test.proxy.Orders.GetOrder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRequest := func(orderID string) *dynamic.Message {
+		req, _ := rpc.RequestType.New()
+		req.(*dynamic.Message).SetFieldByName("order_id", orderID)
+		return req.(*dynamic.Message)
+	}
+	resp, _ := rpc.ResponseType.New()
+	if err = cli.Invoke(ctx, rpc.FullyQualifiedName, newRequest("1"), resp); err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.(*dynamic.Message).GetFieldByName("status"); got != "DONE" {
+		t.Errorf("unexpected status field %v", got)
+	}
+	err = cli.Invoke(ctx, rpc.FullyQualifiedName, newRequest("404"), resp)
+	if st := status.Convert(err); st.Code() != codes.NotFound || st.Message() != "no order" {
+		t.Errorf("unexpected status %v", err)
+	}
+
+	s, err := cli.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, "test.proxy.Orders.WatchOrder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.SendMsg(newRequest("1")); err != nil {
+		t.Fatal(err)
+	}
+	s.CloseSend()
+	var n int
+	for {
+		if err = s.RecvMsg(resp); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("expected 2 streamed responses, got %d", n)
+	}
+
+	p.Stop()
+	recorder.Close()
+	log := out.String()
+	for _, want := range []string{
+		`/test.proxy.Orders/GetOrder metadata:`, `"x-id":["42"]`, `"authorization":["Bearer ***"]`,
+		`request: {"orderId":"1"}`, `response: {"orderId":"1","status":"DONE"}`, "NotFound in", "error: no order",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log does not contain %q:\n%s", want, log)
+		}
+	}
+	if strings.Contains(log, "secret") {
+		t.Errorf("log contains a secret:\n%s", log)
+	}
+
+	recs, err := record.ReadFile(filepath.Join(dir, "calls.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(recs))
+	}
+	if recs[1].Status.Code != codes.NotFound || recs[1].Method != "test.proxy.Orders.GetOrder" {
+		t.Errorf("unexpected record %+v", recs[1])
+	}
+	if got := string(recs[2].Response); got != `[{"status":"NEW"},{"status":"DONE"}]` {
+		t.Errorf("unexpected streamed responses %s", got)
+	}
+}
+
+func TestProxyFaults(t *testing.T) {
+	spec, _, upstreamAddr, cleanup := startUpstream(t)
+	defer cleanup()
+
+	upstreamCli, err := client.NewClient(&client.ClientCfg{Addr: upstreamAddr.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstreamCli.Close()
+	truncate := 1
+	faults := mock.NewFaults(spec,
+		&mock.Fault{
+			Method: "test.proxy.Orders/GetOrder", Match: map[string]interface{}{"order_id": "7"},
+			Error: &mock.Error{Code: codes.Unavailable, Message: "injected"},
+		},
+		&mock.Fault{
+			Method: "test.proxy.Orders/WatchOrder", Truncate: &truncate,
+			Error: &mock.Error{Code: codes.Aborted, Message: "truncated"},
+		},
+	)
+	proxyLis := listen(t)
+	p := New(spec, upstreamCli, Options{}, faults.ServerOptions()...)
+	go p.Serve(faults.Listener(proxyLis))
+	defer p.Stop()
+
+	cli, err := client.NewClient(&client.ClientCfg{Addr: proxyLis.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	rpc, err := spec.LookupRPC("test.proxy.Orders.GetOrder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRequest := func(orderID string) *dynamic.Message {
+		req, _ := rpc.RequestType.New()
+		req.(*dynamic.Message).SetFieldByName("order_id", orderID)
+		return req.(*dynamic.Message)
+	}
+
+	// Proxied calls are streams, the fault is decided on their request.
+	resp, _ := rpc.ResponseType.New()
+	err = cli.Invoke(context.Background(), rpc.FullyQualifiedName, newRequest("7"), resp)
+	if st := status.Convert(err); st.Code() != codes.Unavailable || st.Message() != "injected" {
+		t.Errorf("expected the injected error, got %v", err)
+	}
+	if err = cli.Invoke(context.Background(), rpc.FullyQualifiedName, newRequest("1"), resp); err != nil {
+		t.Errorf("unmatched call failed: %v", err)
+	}
+
+	// Responses are sent by the goroutine forwarding them.
+	s, err := cli.NewStream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, "test.proxy.Orders.WatchOrder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.SendMsg(newRequest("1")); err != nil {
+		t.Fatal(err)
+	}
+	s.CloseSend()
+	var n int
+	for {
+		if err = s.RecvMsg(resp); err != nil {
+			break
+		}
+		n++
+	}
+	if n != 1 || status.Code(err) != codes.Aborted {
+		t.Errorf("expected 1 response and Aborted, got %d and %v", n, err)
+	}
+}
@@ -0,0 +1,89 @@
+// Package query selects parts of JSON responses: fields given as field mask
+// paths and jq-like expressions.
+package query
+
+import (
+	"strings"
+	"unicode"
+)
+
+// fieldTree holds selected fields by normalized name, an empty tree selects
+// the whole value.
+type fieldTree map[string]fieldTree
+
+// Select returns the value with the given fields only. Paths are field mask
+// paths like "order.items.sku", repeated fields select the path of each
+// element. Field names are compared ignoring case and underscores, so both
+// proto and JSON names can be used.
+func Select(v interface{}, paths []string) interface{} {
+	tree := fieldTree{}
+	for _, path := range paths {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		node := tree
+		for _, name := range strings.Split(path, ".") {
+			key := normalize(name)
+			child, ok := node[key]
+			if !ok {
+				child = fieldTree{}
+				node[key] = child
+			}
+			node = child
+		}
+	}
+	if len(tree) == 0 {
+		return v
+	}
+	return tree.prune(v)
+}
+
+func (t fieldTree) prune(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{})
+		for k, field := range v {
+			child, ok := t[normalize(k)]
+			switch {
+			case !ok:
+			case len(child) == 0:
+				out[k] = field
+			default:
+				out[k] = child.prune(field)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			out[i] = t.prune(elem)
+		}
+		return out
+	}
+	return v
+}
+
+// MaskPaths converts paths given with JSON names to proto names of
+// google.protobuf.FieldMask, e.g. "order.createdAt" to "order.created_at".
+func MaskPaths(paths []string) []string {
+	masked := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		var b strings.Builder
+		for _, r := range path {
+			if unicode.IsUpper(r) {
+				b.WriteByte('_')
+				r = unicode.ToLower(r)
+			}
+			b.WriteRune(r)
+		}
+		masked = append(masked, b.String())
+	}
+	return masked
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
@@ -0,0 +1,577 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Query is a jq-like expression: filters separated by "|" are applied to
+// every output of the previous one. Filters are
+//
+//	.                      the input
+//	.order.items[0].sku    fields and indexes, negative ones count from the end
+//	.items[], .items[1:3]  every element, a slice of the array
+//	.["key"], .items[]?    quoted fields, "?" ignores values of other types
+//	length, keys           the length and sorted keys of arrays and objects
+//	select(.total > 10)    the input if a value of the path satisfies the
+//	                       condition: ==, !=, <, <=, >, >= with a JSON
+//	                       literal, or the path alone
+//
+// Field names are compared ignoring case and underscores, like in Select.
+type Query struct {
+	expr    string
+	filters []filter
+}
+
+type filter interface {
+	apply(v interface{}) ([]interface{}, error)
+}
+
+// Parse parses the expression.
+func Parse(expr string) (*Query, error) {
+	p := &parser{s: expr}
+	q := &Query{expr: expr}
+	for {
+		f, err := p.filter()
+		if err != nil {
+			return nil, err
+		}
+		q.filters = append(q.filters, f)
+		p.skipSpace()
+		if p.done() {
+			return q, nil
+		}
+		if !p.consume("|") {
+			return nil, p.errorf("expected |")
+		}
+	}
+}
+
+func (q *Query) String() string {
+	return q.expr
+}
+
+// Run returns outputs of the query for the value decoded from JSON.
+func (q *Query) Run(v interface{}) ([]interface{}, error) {
+	values := []interface{}{v}
+	for _, f := range q.filters {
+		var out []interface{}
+		for _, v := range values {
+			res, err := f.apply(v)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, res...)
+		}
+		values = out
+	}
+	return values, nil
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) errorf(format string, a ...interface{}) error {
+	return errors.Errorf("query: %s at position %d of %q", fmt.Sprintf(format, a...), p.pos+1, p.s)
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *parser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *parser) skipSpace() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\n') {
+		p.pos++
+	}
+}
+
+func (p *parser) consume(token string) bool {
+	if strings.HasPrefix(p.s[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *parser) ident() string {
+	start := p.pos
+	for !p.done() && isIdent(p.peek()) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *parser) filter() (filter, error) {
+	p.skipSpace()
+	if p.peek() == '.' {
+		return p.path()
+	}
+	switch name := p.ident(); name {
+	case "length":
+		return lengthFilter{}, nil
+	case "keys":
+		return keysFilter{}, nil
+	case "select":
+		return p.selectFilter()
+	case "":
+		return nil, p.errorf("expected a filter")
+	default:
+		return nil, p.errorf("unknown function %s", name)
+	}
+}
+
+// step of a path, at most one of name, index, slice and iterate is set.
+type step struct {
+	name     *string
+	index    *int
+	from, to *int
+	slice    bool
+	iterate  bool
+	optional bool
+}
+
+type pathFilter []step
+
+func (p *parser) path() (pathFilter, error) {
+	var path pathFilter
+	p.consume(".")
+	if c := p.peek(); isIdent(c) || c == '"' {
+		s, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, s)
+	}
+	for {
+		switch c := p.peek(); {
+		case c == '.' && p.pos+1 < len(p.s) && (isIdent(p.s[p.pos+1]) || p.s[p.pos+1] == '"'):
+			p.pos++
+			s, err := p.field()
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, s)
+		case c == '.' && p.pos+1 < len(p.s) && p.s[p.pos+1] == '[':
+			p.pos++
+		case c == '[':
+			s, err := p.bracket()
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, s)
+		case c == '?' && len(path) > 0:
+			p.pos++
+			path[len(path)-1].optional = true
+		default:
+			return path, nil
+		}
+	}
+}
+
+func (p *parser) field() (step, error) {
+	if p.peek() == '"' {
+		name, err := p.str()
+		return step{name: &name}, err
+	}
+	name := p.ident()
+	return step{name: &name}, nil
+}
+
+func (p *parser) str() (string, error) {
+	start := p.pos
+	for p.pos++; !p.done(); p.pos++ {
+		switch p.peek() {
+		case '\\':
+			p.pos++
+		case '"':
+			p.pos++
+			var s string
+			if err := json.Unmarshal([]byte(p.s[start:p.pos]), &s); err != nil {
+				return "", p.errorf("invalid string")
+			}
+			return s, nil
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) int() (*int, error) {
+	p.skipSpace()
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for !p.done() && p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		return nil, p.errorf("invalid index %s", p.s[start:p.pos])
+	}
+	return &n, nil
+}
+
+func (p *parser) bracket() (step, error) {
+	p.pos++
+	p.skipSpace()
+	var s step
+	switch {
+	case p.peek() == ']':
+		s.iterate = true
+	case p.peek() == '"':
+		name, err := p.str()
+		if err != nil {
+			return s, err
+		}
+		s.name = &name
+	default:
+		n, err := p.int()
+		if err != nil {
+			return s, err
+		}
+		p.skipSpace()
+		if !p.consume(":") {
+			if n == nil {
+				return s, p.errorf("expected an index")
+			}
+			s.index = n
+			break
+		}
+		s.slice, s.from = true, n
+		if s.to, err = p.int(); err != nil {
+			return s, err
+		}
+	}
+	p.skipSpace()
+	if !p.consume("]") {
+		return s, p.errorf("expected ]")
+	}
+	return s, nil
+}
+
+func (f pathFilter) apply(v interface{}) ([]interface{}, error) {
+	values := []interface{}{v}
+	for _, s := range f {
+		var out []interface{}
+		for _, v := range values {
+			res, err := s.apply(v)
+			if err != nil && !s.optional {
+				return nil, err
+			}
+			out = append(out, res...)
+		}
+		values = out
+	}
+	return values, nil
+}
+
+func (s step) apply(v interface{}) ([]interface{}, error) {
+	switch v := v.(type) {
+	case nil:
+		if s.iterate {
+			return nil, errors.New("query: cannot iterate over null")
+		}
+		return []interface{}{nil}, nil
+	case map[string]interface{}:
+		switch {
+		case s.name != nil:
+			return []interface{}{lookup(v, *s.name)}, nil
+		case s.iterate:
+			out := make([]interface{}, 0, len(v))
+			for _, k := range sortedKeys(v) {
+				out = append(out, v[k])
+			}
+			return out, nil
+		}
+	case []interface{}:
+		switch {
+		case s.index != nil:
+			i := *s.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i < 0 || i >= len(v) {
+				return []interface{}{nil}, nil
+			}
+			return []interface{}{v[i]}, nil
+		case s.slice:
+			from, to := bound(s.from, 0, len(v)), bound(s.to, len(v), len(v))
+			if from > to {
+				from = to
+			}
+			return []interface{}{v[from:to]}, nil
+		case s.iterate:
+			return v, nil
+		}
+	}
+	return nil, errors.Errorf("query: cannot apply %s to %s", s, kind(v))
+}
+
+func (s step) String() string {
+	switch {
+	case s.name != nil:
+		return strconv.Quote(*s.name)
+	case s.index != nil:
+		return fmt.Sprintf("[%d]", *s.index)
+	case s.slice:
+		return "a slice"
+	}
+	return "[]"
+}
+
+// lookup returns the field, matching its name ignoring case and
+// underscores if there is no exact match.
+func lookup(m map[string]interface{}, name string) interface{} {
+	if v, ok := m[name]; ok {
+		return v
+	}
+	for k, v := range m {
+		if normalize(k) == normalize(name) {
+			return v
+		}
+	}
+	return nil
+}
+
+func bound(i *int, def, n int) int {
+	if i == nil {
+		return def
+	}
+	v := *i
+	if v < 0 {
+		v += n
+	}
+	if v < 0 {
+		return 0
+	}
+	if v > n {
+		return n
+	}
+	return v
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func kind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case float64, json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+type lengthFilter struct{}
+
+func (lengthFilter) apply(v interface{}) ([]interface{}, error) {
+	switch v := v.(type) {
+	case nil:
+		return []interface{}{float64(0)}, nil
+	case string:
+		return []interface{}{float64(utf8.RuneCountInString(v))}, nil
+	case []interface{}:
+		return []interface{}{float64(len(v))}, nil
+	case map[string]interface{}:
+		return []interface{}{float64(len(v))}, nil
+	}
+	return nil, errors.Errorf("query: %s has no length", kind(v))
+}
+
+type keysFilter struct{}
+
+func (keysFilter) apply(v interface{}) ([]interface{}, error) {
+	switch v := v.(type) {
+	case []interface{}:
+		keys := make([]interface{}, len(v))
+		for i := range v {
+			keys[i] = float64(i)
+		}
+		return []interface{}{keys}, nil
+	case map[string]interface{}:
+		keys := make([]interface{}, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			keys = append(keys, k)
+		}
+		return []interface{}{keys}, nil
+	}
+	return nil, errors.Errorf("query: %s has no keys", kind(v))
+}
+
+// selectFilter passes inputs with a value of the path satisfying the
+// condition, a path without a condition is satisfied by values other than
+// null and false.
+type selectFilter struct {
+	path    pathFilter
+	op      string
+	literal interface{}
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func (p *parser) selectFilter() (filter, error) {
+	p.skipSpace()
+	if !p.consume("(") {
+		return nil, p.errorf("expected (")
+	}
+	p.skipSpace()
+	if p.peek() != '.' {
+		return nil, p.errorf("expected a path")
+	}
+	path, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	f := &selectFilter{path: path}
+	p.skipSpace()
+	for _, op := range operators {
+		if p.consume(op) {
+			f.op = op
+			break
+		}
+	}
+	if f.op != "" {
+		if f.literal, err = p.literal(); err != nil {
+			return nil, err
+		}
+	}
+	p.skipSpace()
+	if !p.consume(")") {
+		return nil, p.errorf("expected )")
+	}
+	return f, nil
+}
+
+// literal parses a JSON string, number, boolean or null.
+func (p *parser) literal() (interface{}, error) {
+	p.skipSpace()
+	if p.peek() == '"' {
+		s, err := p.str()
+		return s, err
+	}
+	start := p.pos
+	for !p.done() && p.peek() != ')' && p.peek() != ' ' {
+		p.pos++
+	}
+	raw := p.s[start:p.pos]
+	var v interface{}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil, p.errorf("invalid literal %q", raw)
+	}
+	return v, nil
+}
+
+func (f *selectFilter) apply(v interface{}) ([]interface{}, error) {
+	values, err := f.path.apply(v)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		if f.holds(value) {
+			return []interface{}{v}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *selectFilter) holds(v interface{}) bool {
+	switch f.op {
+	case "":
+		return v != nil && v != false
+	case "==":
+		return equal(v, f.literal)
+	case "!=":
+		return !equal(v, f.literal)
+	}
+	c, ok := compare(v, f.literal)
+	if !ok {
+		return false
+	}
+	switch f.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+func equal(a, b interface{}) bool {
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare orders numbers and strings, 64-bit integers encoded as JSON
+// strings are compared as numbers.
+func compare(a, b interface{}) (int, bool) {
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	x, okA := a.(string)
+	y, okB := b.(string)
+	if !okA || !okB {
+		return 0, false
+	}
+	return strings.Compare(x, y), true
+}
+
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
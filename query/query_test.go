@@ -0,0 +1,89 @@
+package query
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const order = `{
+  "orderId": "1",
+  "total": "1250",
+  "customer": {"name": "Ann", "email": "ann@example.com"},
+  "items": [
+    {"sku": "a", "qty": 1, "tags": ["new"]},
+    {"sku": "b", "qty": 3},
+    {"sku": "c", "qty": 5}
+  ]
+}`
+
+func decode(t *testing.T, s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestSelect(t *testing.T) {
+	got := Select(decode(t, order), []string{"order_id", "items.sku", "customer"})
+	want := decode(t, `{
+	  "orderId": "1",
+	  "customer": {"name": "Ann", "email": "ann@example.com"},
+	  "items": [{"sku": "a"}, {"sku": "b"}, {"sku": "c"}]
+	}`)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := MaskPaths([]string{"orderId", "items.sku "}); !reflect.DeepEqual(got, []string{"order_id", "items.sku"}) {
+		t.Errorf("unexpected mask paths %q", got)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: ".", want: `[` + order + `]`},
+		{expr: ".customer.name", want: `["Ann"]`},
+		{expr: ".order_id", want: `["1"]`},
+		{expr: `.["customer"].email`, want: `["ann@example.com"]`},
+		{expr: ".items[].sku", want: `["a","b","c"]`},
+		{expr: ".items[-1].qty", want: `[5]`},
+		{expr: ".items[1:] | length", want: `[2]`},
+		{expr: ".items[5]", want: `[null]`},
+		{expr: ".customer | keys", want: `[["email","name"]]`},
+		{expr: `.items[] | select(.qty >= 3) | .sku`, want: `["b","c"]`},
+		{expr: `.items[] | select(.sku != "a") | .qty`, want: `[3,5]`},
+		{expr: `.items[] | select(.tags) | .sku`, want: `["a"]`},
+		{expr: `select(.total > 1000) | .orderId`, want: `["1"]`},
+		{expr: ".items[].tags[]?", want: `["new"]`},
+	}
+	v := decode(t, order)
+	for _, tt := range tests {
+		q, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		got, err := q.Run(v)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if want := decode(t, tt.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", tt.expr, got, want)
+		}
+	}
+
+	for _, expr := range []string{"items", ".items[", ".a | first", `select(.a == x)`, ". .a"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("%s: expected a syntax error", expr)
+		}
+	}
+	q, _ := Parse(".items[].tags[]")
+	if _, err := q.Run(v); err == nil {
+		t.Error("expected an error iterating over null")
+	}
+}
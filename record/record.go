@@ -0,0 +1,87 @@
+package record
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// Record is a single recorded call, stored as a line of a JSON lines file.
+type Record struct {
+	Time       time.Time           `json:"time"`
+	Target     string              `json:"target"`
+	Method     string              `json:"method"`
+	Metadata   map[string][]string `json:"metadata,omitempty"`
+	Request    json.RawMessage     `json:"request"`
+	Response   json.RawMessage     `json:"response,omitempty"`
+	Status     Status              `json:"status"`
+	Header     map[string][]string `json:"header,omitempty"`
+	Trailer    map[string][]string `json:"trailer,omitempty"`
+	DurationMs float64             `json:"duration_ms"`
+}
+
+// Status is the gRPC status of the recorded call.
+type Status struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message,omitempty"`
+}
+
+// Recorder appends records to a file, it is safe for concurrent use.
+type Recorder struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	enc  *json.Encoder
+}
+
+// Open opens the file for appending, creating it if needed.
+func Open(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "record: failed to open file")
+	}
+	return &Recorder{path: path, f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Path returns the file the recorder writes to.
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Write appends the record.
+func (r *Recorder) Write(rec *Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return errors.Wrap(r.enc.Encode(rec), "record: failed to write")
+}
+
+// Close closes the underlying file.
+func (r *Recorder) Close() error {
+	return r.f.Close()
+}
+
+// ReadFile returns all records of the file.
+func ReadFile(path string) ([]*Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "record: failed to open file")
+	}
+	defer f.Close()
+
+	var recs []*Record
+	dec := json.NewDecoder(f)
+	for {
+		rec := new(Record)
+		if err = dec.Decode(rec); err == io.EOF {
+			return recs, nil
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "record: failed to decode record %d", len(recs)+1)
+		}
+		recs = append(recs, rec)
+	}
+}
@@ -0,0 +1,168 @@
+package redact
+
+import (
+	"path"
+	"strings"
+
+	"github.com/alexej-v/grpc_cli/cmdline"
+)
+
+// Mask replaces sensitive values.
+const Mask = "***"
+
+// DefaultPatterns are header names masked unless configured otherwise.
+var DefaultPatterns = []string{"authorization", "cookie", "set-cookie", "x-api-key"}
+
+// Redactor masks values of sensitive headers. Header names are matched
+// case-insensitively against glob patterns like "x-*-token".
+type Redactor struct {
+	patterns []string
+}
+
+// New returns a redactor for the patterns.
+func New(patterns []string) *Redactor {
+	r := &Redactor{patterns: make([]string, 0, len(patterns))}
+	for _, p := range patterns {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			r.patterns = append(r.patterns, p)
+		}
+	}
+	return r
+}
+
+// Patterns returns the configured patterns.
+func (r *Redactor) Patterns() []string {
+	return r.patterns
+}
+
+// Sensitive reports whether values of the header must be masked.
+func (r *Redactor) Sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, p := range r.patterns {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// authSchemes are kept in masked authorization values.
+var authSchemes = map[string]bool{
+	"basic": true, "bearer": true, "digest": true, "dpop": true, "hoba": true,
+	"mutual": true, "negotiate": true, "ntlm": true, "token": true, "aws4-hmac-sha256": true,
+}
+
+// Value masks the value if the header is sensitive. A known scheme of
+// authorization values like "Bearer" is kept, other values are masked fully.
+func (r *Redactor) Value(key, value string) string {
+	if !r.Sensitive(key) {
+		return value
+	}
+	switch strings.ToLower(key) {
+	case "authorization", "proxy-authorization":
+		if i := strings.Index(value, " "); i > 0 && authSchemes[strings.ToLower(value[:i])] {
+			return value[:i] + " " + Mask
+		}
+	}
+	return Mask
+}
+
+// Map returns a copy of headers with masked values.
+func (r *Redactor) Map(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(headers))
+	for k, v := range headers {
+		masked[k] = r.Value(k, v)
+	}
+	return masked
+}
+
+// MD returns a copy of metadata with masked values.
+func (r *Redactor) MD(md map[string][]string) map[string][]string {
+	if md == nil {
+		return nil
+	}
+	masked := make(map[string][]string, len(md))
+	for k, vals := range md {
+		masked[k] = make([]string, len(vals))
+		for i, v := range vals {
+			masked[k][i] = r.Value(k, v)
+		}
+	}
+	return masked
+}
+
+// Line masks secrets of a shell command line before it is stored in
+// history: values of "set header <key> <value>" and "<key>:<value>" pairs,
+// also quoted ones and flag values like --header=<key>:<value>, tokens
+// given to "token" and commands and files of "set token-exec|token-file".
+func (r *Redactor) Line(line string) string {
+	if masked, ok := credentials(strings.Split(strings.TrimSpace(line), cmdline.Delimiter)); ok {
+		return strings.Join(masked, cmdline.Delimiter)
+	}
+	fields := strings.Split(line, cmdline.Delimiter)
+	out := make([]string, 0, len(fields))
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == "header" && i+2 < len(fields) && r.Sensitive(fields[i+1]) {
+			value := strings.Join(fields[i+2:], cmdline.Delimiter)
+			return strings.Join(append(append(out, fields[i:i+2]...), r.Value(fields[i+1], value)), cmdline.Delimiter)
+		}
+		field, flag := fields[i], ""
+		if j := strings.Index(field, "="); j > 0 && strings.HasPrefix(field, "-") {
+			flag, field = field[:j+1], field[j+1:]
+		}
+		value, n := cmdline.JoinQuoted(field, fields[i+1:])
+		var open, close string
+		if value != field || n > 0 {
+			open = field[:1]
+			// Unterminated values are returned with their quote.
+			if raw := strings.Join(append([]string{field}, fields[i+1:i+1+n]...), cmdline.Delimiter); len(raw) > 1 && strings.HasSuffix(raw, open) {
+				close = open
+			} else {
+				value = value[1:]
+			}
+		}
+		if masked, ok := r.pair(value); ok {
+			out = append(out, flag+open+masked+close)
+			i += n
+			continue
+		}
+		out = append(out, fields[i])
+	}
+	return strings.Join(out, cmdline.Delimiter)
+}
+
+// credentials masks the token argument of "token" and the value of the
+// credential provider settings.
+func credentials(fields []string) ([]string, bool) {
+	switch {
+	case len(fields) > 2 && fields[0] == "set" && (fields[1] == "token-exec" || fields[1] == "token-file"):
+		return append(fields[:2:2], Mask), true
+	case len(fields) > 1 && fields[0] == "token":
+		out := fields[:1:1]
+		for i := 1; i < len(fields); i++ {
+			switch f := fields[i]; {
+			case (f == "-jwks" || f == "--jwks") && i+1 < len(fields):
+				out = append(out, f, fields[i+1])
+				i++
+			case strings.HasPrefix(f, "-"):
+				out = append(out, f)
+			default:
+				return append(out, Mask), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// pair masks the value of a "<key>:<value>" pair of a sensitive header.
+func (r *Redactor) pair(s string) (string, bool) {
+	j := strings.Index(s, ":")
+	if j <= 0 || !r.Sensitive(strings.TrimSpace(s[:j])) {
+		return "", false
+	}
+	value := strings.TrimLeft(s[j+1:], " ")
+	return s[:len(s)-len(value)] + r.Value(strings.TrimSpace(s[:j]), value), true
+}
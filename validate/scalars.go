@@ -0,0 +1,378 @@
+package validate
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// scalar evaluates rules of the kind (string, int32, enum...) for the value.
+func (v *validator) scalar(path, kind string, fd *desc.FieldDescriptor, val interface{}, rules *dynamic.Message) {
+	number := kind != "string" && kind != "bytes" && kind != "bool"
+	if number {
+		v.numberRange(path, kind, val, rules)
+	}
+	for _, rfd := range rules.GetMessageDescriptor().GetFields() {
+		if !rules.HasField(rfd) {
+			continue
+		}
+		name, rule := rfd.GetName(), rules.GetField(rfd)
+		if number && boundWords[name] != "" {
+			continue
+		}
+		id := kind + "." + name
+		switch kind {
+		case "string":
+			s, _ := val.(string)
+			v.stringRule(path, id, name, s, rule)
+		case "bytes":
+			b, _ := val.([]byte)
+			v.bytesRule(path, id, name, b, rule)
+		case "bool":
+			if name == "const" && val != rule {
+				v.addf(path, id, "must equal %v", rule)
+			}
+		case "enum":
+			if name == "defined_only" && rule == true && fd.GetEnumType() != nil {
+				if num, _ := val.(int32); fd.GetEnumType().FindValueByNumber(num) == nil {
+					v.addf(path, id, "must be a defined enum value")
+				}
+				continue
+			}
+			v.numberRule(path, id, name, val, rule)
+		default:
+			v.numberRule(path, id, name, val, rule)
+		}
+	}
+}
+
+func (v *validator) numberRule(path, id, name string, val, rule interface{}) {
+	switch name {
+	case "const":
+		if compare(val, rule) != 0 {
+			v.addf(path, id, "must equal %v", rule)
+		}
+	case "in":
+		if !in(val, rule) {
+			v.addf(path, id, "must be in %v", rule)
+		}
+	case "not_in":
+		if in(val, rule) {
+			v.addf(path, id, "must not be in %v", rule)
+		}
+	}
+}
+
+var boundWords = map[string]string{
+	"gt":  "greater than",
+	"gte": "greater than or equal to",
+	"lt":  "less than",
+	"lte": "less than or equal to",
+}
+
+// numberRange evaluates gt, gte, lt and lte as a pair: a lower bound above
+// the upper one is an exclusive range, the value must lie outside of it.
+func (v *validator) numberRange(path, kind string, val interface{}, rules *dynamic.Message) {
+	lo, loName := bound(rules, "gt", "gte")
+	hi, hiName := bound(rules, "lt", "lte")
+	above := loName == "gt" && compare(val, lo) > 0 || loName == "gte" && compare(val, lo) >= 0
+	below := hiName == "lt" && compare(val, hi) < 0 || hiName == "lte" && compare(val, hi) <= 0
+	switch {
+	case loName == "" && hiName == "":
+	case hiName == "":
+		if !above {
+			v.addf(path, kind+"."+loName, "must be %s %v", boundWords[loName], lo)
+		}
+	case loName == "":
+		if !below {
+			v.addf(path, kind+"."+hiName, "must be %s %v", boundWords[hiName], hi)
+		}
+	case compare(hi, lo) >= 0:
+		if !above || !below {
+			v.addf(path, kind+"."+loName+"_"+hiName, "must be %s %v and %s %v",
+				boundWords[loName], lo, boundWords[hiName], hi)
+		}
+	default:
+		if !above && !below {
+			v.addf(path, kind+"."+loName+"_"+hiName+"_exclusive", "must be %s %v or %s %v",
+				boundWords[loName], lo, boundWords[hiName], hi)
+		}
+	}
+}
+
+// bound returns the first set rule of the names and its name.
+func bound(rules *dynamic.Message, names ...string) (interface{}, string) {
+	for _, name := range names {
+		fd := rules.GetMessageDescriptor().FindFieldByName(name)
+		if fd != nil && rules.HasField(fd) {
+			return rules.GetField(fd), name
+		}
+	}
+	return nil, ""
+}
+
+func (v *validator) stringRule(path, id, name, s string, rule interface{}) {
+	n := uint64(utf8.RuneCountInString(s))
+	switch name {
+	case "const":
+		if s != rule {
+			v.addf(path, id, "must equal %q", rule)
+		}
+	case "len":
+		if l := toUint(rule); n != l {
+			v.addf(path, id, "must be %d characters", l)
+		}
+	case "min_len":
+		if l := toUint(rule); n < l {
+			v.addf(path, id, "must be at least %d characters", l)
+		}
+	case "max_len":
+		if l := toUint(rule); n > l {
+			v.addf(path, id, "must be at most %d characters", l)
+		}
+	case "len_bytes":
+		if l := toUint(rule); uint64(len(s)) != l {
+			v.addf(path, id, "must be %d bytes", l)
+		}
+	case "min_bytes":
+		if l := toUint(rule); uint64(len(s)) < l {
+			v.addf(path, id, "must be at least %d bytes", l)
+		}
+	case "max_bytes":
+		if l := toUint(rule); uint64(len(s)) > l {
+			v.addf(path, id, "must be at most %d bytes", l)
+		}
+	case "pattern":
+		re, err := regexp.Compile(rule.(string))
+		if err == nil && !re.MatchString(s) {
+			v.addf(path, id, "must match pattern %q", rule)
+		}
+	case "prefix":
+		if !strings.HasPrefix(s, rule.(string)) {
+			v.addf(path, id, "must have prefix %q", rule)
+		}
+	case "suffix":
+		if !strings.HasSuffix(s, rule.(string)) {
+			v.addf(path, id, "must have suffix %q", rule)
+		}
+	case "contains":
+		if !strings.Contains(s, rule.(string)) {
+			v.addf(path, id, "must contain %q", rule)
+		}
+	case "not_contains":
+		if strings.Contains(s, rule.(string)) {
+			v.addf(path, id, "must not contain %q", rule)
+		}
+	case "in":
+		if !in(s, rule) {
+			v.addf(path, id, "must be in %v", rule)
+		}
+	case "not_in":
+		if in(s, rule) {
+			v.addf(path, id, "must not be in %v", rule)
+		}
+	default:
+		if rule != true {
+			return
+		}
+		if ok, known := wellKnown(name, s); known && !ok {
+			v.addf(path, id, "must be a valid %s", strings.Replace(name, "_", " ", -1))
+		}
+	}
+}
+
+func (v *validator) bytesRule(path, id, name string, b []byte, rule interface{}) {
+	n := uint64(len(b))
+	switch name {
+	case "const":
+		if !bytes.Equal(b, rule.([]byte)) {
+			v.addf(path, id, "must equal %x", rule)
+		}
+	case "len":
+		if l := toUint(rule); n != l {
+			v.addf(path, id, "must be %d bytes", l)
+		}
+	case "min_len":
+		if l := toUint(rule); n < l {
+			v.addf(path, id, "must be at least %d bytes", l)
+		}
+	case "max_len":
+		if l := toUint(rule); n > l {
+			v.addf(path, id, "must be at most %d bytes", l)
+		}
+	case "pattern":
+		re, err := regexp.Compile(rule.(string))
+		if err == nil && !re.Match(b) {
+			v.addf(path, id, "must match pattern %q", rule)
+		}
+	case "prefix":
+		if !bytes.HasPrefix(b, rule.([]byte)) {
+			v.addf(path, id, "must have prefix %x", rule)
+		}
+	case "suffix":
+		if !bytes.HasSuffix(b, rule.([]byte)) {
+			v.addf(path, id, "must have suffix %x", rule)
+		}
+	case "contains":
+		if !bytes.Contains(b, rule.([]byte)) {
+			v.addf(path, id, "must contain %x", rule)
+		}
+	case "in":
+		if !inBytes(b, rule) {
+			v.addf(path, id, "must be one of the listed values")
+		}
+	case "not_in":
+		if inBytes(b, rule) {
+			v.addf(path, id, "must not be one of the listed values")
+		}
+	case "ip", "ipv4", "ipv6":
+		if rule != true {
+			return
+		}
+		ip := net.IP(b)
+		if len(b) != net.IPv4len && len(b) != net.IPv6len ||
+			name == "ipv4" && len(b) != net.IPv4len || name == "ipv6" && len(b) != net.IPv6len {
+			v.addf(path, id, "must be a valid %s address, got %s", name, ip)
+		}
+	}
+}
+
+// wellKnown checks string formats, known is false for unsupported ones.
+func wellKnown(name, s string) (ok, known bool) {
+	switch name {
+	case "email":
+		a, err := mail.ParseAddress(s)
+		return err == nil && a.Address == s, true
+	case "hostname":
+		return isHostname(s), true
+	case "ip":
+		return net.ParseIP(s) != nil, true
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil, true
+	case "ipv6":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() == nil, true
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.IsAbs(), true
+	case "uri_ref":
+		_, err := url.Parse(s)
+		return err == nil, true
+	case "address":
+		return net.ParseIP(s) != nil || isHostname(s), true
+	case "uuid":
+		return uuidRe.MatchString(s), true
+	}
+	return false, false
+}
+
+func isHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func in(val, list interface{}) bool {
+	items, _ := list.([]interface{})
+	for _, item := range items {
+		if compare(val, item) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func inBytes(b []byte, list interface{}) bool {
+	items, _ := list.([]interface{})
+	for _, item := range items {
+		if e, ok := item.([]byte); ok && bytes.Equal(b, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare orders values of the same proto scalar type.
+func compare(a, b interface{}) int {
+	switch x := a.(type) {
+	case int32, int64:
+		i, j := toInt(x), toInt(b)
+		return cmp(i < j, i > j)
+	case uint32, uint64:
+		i, j := toUint(x), toUint(b)
+		return cmp(i < j, i > j)
+	case float32, float64:
+		i, j := toFloat(x), toFloat(b)
+		return cmp(i < j, i > j)
+	case string:
+		y, _ := b.(string)
+		return strings.Compare(x, y)
+	}
+	if fmt.Sprint(a) == fmt.Sprint(b) {
+		return 0
+	}
+	return -1
+}
+
+func cmp(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+func toInt(v interface{}) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	}
+	return 0
+}
+
+func toUint(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint32:
+		return uint64(n)
+	case uint64:
+		return n
+	}
+	return 0
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float32:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
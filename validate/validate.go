@@ -0,0 +1,392 @@
+// Package validate evaluates protoc-gen-validate (validate.rules) and
+// protovalidate (buf.validate) constraints of a dynamic message locally.
+//
+// Standard field rules are supported. CEL expressions and duration and
+// timestamp ranges are not evaluated and are left to the server.
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+// Extension numbers of the rules on field, message and oneof options.
+const (
+	pgvExtension = 1071
+	bufExtension = 1159
+)
+
+// Violation of a field rule.
+type Violation struct {
+	// Field is the path of the field, e.g. "items[0].name".
+	Field string
+	// Rule is the rule name, e.g. "string.min_len".
+	Rule    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s [%s]", v.Field, v.Message, v.Rule)
+}
+
+// Message checks the message and its nested messages against the rules of
+// their descriptors.
+func Message(msg *dynamic.Message) []Violation {
+	er := dynamic.NewExtensionRegistryWithDefaults()
+	er.AddExtensionsFromFileRecursively(msg.GetMessageDescriptor().GetFile())
+	v := &validator{er: er}
+	v.message("", msg)
+	return v.violations
+}
+
+type validator struct {
+	er         *dynamic.ExtensionRegistry
+	violations []Violation
+}
+
+func (v *validator) addf(path, rule, format string, a ...interface{}) {
+	v.violations = append(v.violations, Violation{Field: path, Rule: rule, Message: fmt.Sprintf(format, a...)})
+}
+
+func (v *validator) message(path string, msg *dynamic.Message) {
+	md := msg.GetMessageDescriptor()
+	if opts := md.GetMessageOptions(); opts != nil && v.option(opts, "disabled") {
+		return
+	}
+	for _, od := range md.GetOneOfs() {
+		if opts := od.GetOneOfOptions(); opts == nil || !v.option(opts, "required") {
+			continue
+		}
+		if fd, _ := msg.GetOneOfField(od); fd == nil {
+			v.addf(join(path, od.GetName()), "oneof.required", "exactly one field is required")
+		}
+	}
+	for _, fd := range md.GetFields() {
+		var rules *dynamic.Message
+		if opts := fd.GetFieldOptions(); opts != nil {
+			rules = v.extension(opts)
+		}
+		v.field(join(path, fd.GetName()), fd, msg, rules)
+	}
+}
+
+// extension returns the validate.rules or buf.validate option of the
+// options message, or nil.
+func (v *validator) extension(opts proto.Message) *dynamic.Message {
+	for _, val := range v.extensions(opts) {
+		if rules, ok := val.(*dynamic.Message); ok {
+			return rules
+		}
+	}
+	return nil
+}
+
+// option reports whether a message or oneof flag is set: validate.disabled
+// and validate.required are bool options, buf.validate ones are fields.
+func (v *validator) option(opts proto.Message, name string) bool {
+	for _, val := range v.extensions(opts) {
+		switch val := val.(type) {
+		case bool:
+			if val {
+				return true
+			}
+		case *dynamic.Message:
+			if isTrue(val, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (v *validator) extensions(opts proto.Message) []interface{} {
+	dm, err := dynamic.AsDynamicMessageWithExtensionRegistry(opts, v.er)
+	if err != nil {
+		return nil
+	}
+	var vals []interface{}
+	for _, num := range []int{pgvExtension, bufExtension} {
+		if dm.HasFieldNumber(num) {
+			vals = append(vals, dm.GetFieldByNumber(num))
+		}
+	}
+	return vals
+}
+
+func (v *validator) field(path string, fd *desc.FieldDescriptor, msg *dynamic.Message, rules *dynamic.Message) {
+	set := msg.HasField(fd)
+	val := msg.GetField(fd)
+	if rules != nil {
+		if ignored(rules, set) {
+			return
+		}
+		if isTrue(rules, "required") && !set {
+			v.addf(path, "required", "value is required")
+			return
+		}
+		if !v.rules(path, fd, val, set, rules) {
+			return
+		}
+	}
+	if fd.GetMessageType() == nil || !set {
+		return
+	}
+	switch {
+	case fd.IsMap():
+		if fd.GetMapValueType().GetMessageType() == nil {
+			return
+		}
+		for k, e := range val.(map[interface{}]interface{}) {
+			v.nested(fmt.Sprintf("%s[%v]", path, k), e)
+		}
+	case fd.IsRepeated():
+		for i, e := range val.([]interface{}) {
+			v.nested(fmt.Sprintf("%s[%d]", path, i), e)
+		}
+	default:
+		v.nested(path, val)
+	}
+}
+
+func (v *validator) nested(path string, val interface{}) {
+	m, ok := val.(proto.Message)
+	if !ok {
+		return
+	}
+	dm, err := dynamic.AsDynamicMessage(m)
+	if err != nil {
+		return
+	}
+	v.message(path, dm)
+}
+
+// rules evaluates the type rules of FieldRules, it returns false if nested
+// messages must not be validated.
+func (v *validator) rules(path string, fd *desc.FieldDescriptor, val interface{}, set bool, rules *dynamic.Message) bool {
+	for _, rfd := range rules.GetMessageDescriptor().GetFields() {
+		if rfd.GetMessageType() == nil || !rules.HasField(rfd) {
+			continue
+		}
+		typeRules, ok := rules.GetField(rfd).(*dynamic.Message)
+		if !ok {
+			continue
+		}
+		kind := rfd.GetName()
+		switch kind {
+		case "message":
+			if isTrue(typeRules, "skip") {
+				return false
+			}
+			if isTrue(typeRules, "required") && !set {
+				v.addf(path, "message.required", "value is required")
+			}
+		case "repeated":
+			v.repeated(path, fd, val.([]interface{}), typeRules)
+		case "map":
+			v.mapRules(path, fd, val.(map[interface{}]interface{}), typeRules)
+		case "any", "duration", "timestamp":
+			if isTrue(typeRules, "required") && !set {
+				v.addf(path, kind+".required", "value is required")
+			}
+		default:
+			if fd.IsRepeated() {
+				continue
+			}
+			if value, wrapped := unwrap(fd, val); value != nil {
+				// Rules of unset wrappers are skipped.
+				if !set {
+					continue
+				}
+				v.scalar(path, kind, value, wrapped, typeRules)
+				continue
+			}
+			v.scalar(path, kind, fd, val, typeRules)
+		}
+	}
+	return true
+}
+
+// unwrap returns the value field of google.protobuf wrapper messages like
+// StringValue and its value, the field is nil for other fields.
+func unwrap(fd *desc.FieldDescriptor, val interface{}) (*desc.FieldDescriptor, interface{}) {
+	mt := fd.GetMessageType()
+	if mt == nil || !strings.HasPrefix(mt.GetFullyQualifiedName(), "google.protobuf.") ||
+		!strings.HasSuffix(mt.GetName(), "Value") {
+		return nil, nil
+	}
+	value := mt.FindFieldByName("value")
+	if value == nil {
+		return nil, nil
+	}
+	m, ok := val.(proto.Message)
+	if !ok {
+		return nil, nil
+	}
+	dm, err := dynamic.AsDynamicMessage(m)
+	if err != nil {
+		return nil, nil
+	}
+	return value, dm.GetField(value)
+}
+
+func (v *validator) repeated(path string, fd *desc.FieldDescriptor, items []interface{}, rules *dynamic.Message) {
+	if isTrue(rules, "ignore_empty") && len(items) == 0 {
+		return
+	}
+	if n, ok := uintRule(rules, "min_items"); ok && uint64(len(items)) < n {
+		v.addf(path, "repeated.min_items", "must contain at least %d item(s)", n)
+	}
+	if n, ok := uintRule(rules, "max_items"); ok && uint64(len(items)) > n {
+		v.addf(path, "repeated.max_items", "must contain at most %d item(s)", n)
+	}
+	if isTrue(rules, "unique") {
+		seen := make(map[string]bool, len(items))
+		for _, item := range items {
+			key := fmt.Sprint(item)
+			if b, ok := item.([]byte); ok {
+				key = string(b)
+			}
+			if seen[key] {
+				v.addf(path, "repeated.unique", "items must be unique")
+				break
+			}
+			seen[key] = true
+		}
+	}
+	itemRules := messageRule(rules, "items")
+	if itemRules == nil {
+		return
+	}
+	for i, item := range items {
+		v.element(fmt.Sprintf("%s[%d]", path, i), fd, item, itemRules)
+	}
+}
+
+func (v *validator) mapRules(path string, fd *desc.FieldDescriptor, pairs map[interface{}]interface{}, rules *dynamic.Message) {
+	if isTrue(rules, "ignore_empty") && len(pairs) == 0 {
+		return
+	}
+	if n, ok := uintRule(rules, "min_pairs"); ok && uint64(len(pairs)) < n {
+		v.addf(path, "map.min_pairs", "must contain at least %d pair(s)", n)
+	}
+	if n, ok := uintRule(rules, "max_pairs"); ok && uint64(len(pairs)) > n {
+		v.addf(path, "map.max_pairs", "must contain at most %d pair(s)", n)
+	}
+	keyRules, valueRules := messageRule(rules, "keys"), messageRule(rules, "values")
+	for k, val := range pairs {
+		p := fmt.Sprintf("%s[%v]", path, k)
+		if keyRules != nil {
+			v.element(p, fd.GetMapKeyType(), k, keyRules)
+		}
+		if valueRules != nil {
+			v.element(p, fd.GetMapValueType(), val, valueRules)
+		}
+	}
+}
+
+// element evaluates item, key or value rules of a repeated or map field.
+func (v *validator) element(path string, fd *desc.FieldDescriptor, val interface{}, rules *dynamic.Message) {
+	if ignored(rules, !isZero(val)) {
+		return
+	}
+	for _, rfd := range rules.GetMessageDescriptor().GetFields() {
+		if rfd.GetMessageType() == nil || !rules.HasField(rfd) {
+			continue
+		}
+		typeRules, ok := rules.GetField(rfd).(*dynamic.Message)
+		if !ok {
+			continue
+		}
+		switch kind := rfd.GetName(); kind {
+		case "message":
+			if !isTrue(typeRules, "skip") {
+				v.nested(path, val)
+			}
+		case "repeated", "map", "any", "duration", "timestamp":
+		default:
+			if value, wrapped := unwrap(fd, val); value != nil {
+				v.scalar(path, kind, value, wrapped, typeRules)
+				continue
+			}
+			v.scalar(path, kind, fd, val, typeRules)
+		}
+	}
+}
+
+// ignored reports whether rules are skipped for the value by "ignore_empty"
+// or the protovalidate "ignore" setting.
+func ignored(rules *dynamic.Message, set bool) bool {
+	if isTrue(rules, "ignore_empty") && !set {
+		return true
+	}
+	fd := rules.GetMessageDescriptor().FindFieldByName("ignore")
+	if fd == nil || fd.GetEnumType() == nil || !rules.HasField(fd) {
+		return false
+	}
+	num, _ := rules.GetField(fd).(int32)
+	ev := fd.GetEnumType().FindValueByNumber(num)
+	if ev == nil {
+		return false
+	}
+	name := ev.GetName()
+	switch {
+	case strings.HasSuffix(name, "ALWAYS"):
+		return true
+	case strings.HasSuffix(name, "UNPOPULATED"), strings.HasSuffix(name, "EMPTY"), strings.HasSuffix(name, "DEFAULT_VALUE"):
+		return !set
+	}
+	return false
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func isTrue(m *dynamic.Message, name string) bool {
+	fd := m.GetMessageDescriptor().FindFieldByName(name)
+	if fd == nil || !m.HasField(fd) {
+		return false
+	}
+	b, _ := m.GetField(fd).(bool)
+	return b
+}
+
+func uintRule(m *dynamic.Message, name string) (uint64, bool) {
+	fd := m.GetMessageDescriptor().FindFieldByName(name)
+	if fd == nil || !m.HasField(fd) {
+		return 0, false
+	}
+	return toUint(m.GetField(fd)), true
+}
+
+func messageRule(m *dynamic.Message, name string) *dynamic.Message {
+	fd := m.GetMessageDescriptor().FindFieldByName(name)
+	if fd == nil || !m.HasField(fd) {
+		return nil
+	}
+	rules, _ := m.GetField(fd).(*dynamic.Message)
+	return rules
+}
+
+func isZero(val interface{}) bool {
+	switch v := val.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []byte:
+		return len(v) == 0
+	case bool:
+		return !v
+	case proto.Message:
+		return false
+	}
+	return fmt.Sprint(val) == "0"
+}
@@ -0,0 +1,156 @@
+package validate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+// validateProto is a subset of protoc-gen-validate's validate.proto.
+const validateProto = `syntax = "proto2";
+package validate;
+import "google/protobuf/descriptor.proto";
+
+extend google.protobuf.OneofOptions { optional bool required = 1071; }
+extend google.protobuf.FieldOptions { optional FieldRules rules = 1071; }
+
+message FieldRules {
+  optional MessageRules message = 17;
+  oneof type {
+    Int32Rules int32 = 3;
+    StringRules string = 14;
+    EnumRules enum = 16;
+    RepeatedRules repeated = 18;
+  }
+}
+message Int32Rules { optional int32 lt = 2; optional int32 gt = 4; optional int32 lte = 3; repeated int32 in = 6; }
+message StringRules {
+  optional uint64 min_len = 2;
+  optional string pattern = 6;
+  oneof well_known { bool email = 12; bool uuid = 22; }
+}
+message EnumRules { optional bool defined_only = 2; }
+message MessageRules { optional bool skip = 1; optional bool required = 2; }
+message RepeatedRules {
+  optional uint64 min_items = 1;
+  optional bool unique = 3;
+  optional FieldRules items = 4;
+}
+`
+
+const ordersProto = `syntax = "proto3";
+package test.validate;
+import "validate/validate.proto";
+import "google/protobuf/wrappers.proto";
+
+enum Status { UNKNOWN = 0; NEW = 1; }
+
+message Item {
+  string sku = 1 [(validate.rules).string.pattern = "^[A-Z]+-[0-9]+$"];
+}
+
+message CreateOrderRequest {
+  string order_id = 1 [(validate.rules).string.uuid = true];
+  string email = 2 [(validate.rules).string.email = true];
+  int32 quantity = 3 [(validate.rules).int32 = {gt: 0, lte: 100}];
+  Status status = 4 [(validate.rules).enum.defined_only = true];
+  repeated string tags = 5 [(validate.rules).repeated = {min_items: 1, unique: true, items: {string: {min_len: 2}}}];
+  Item item = 6 [(validate.rules).message.required = true];
+  repeated Item extra = 7;
+  oneof payment {
+    option (validate.required) = true;
+    string card = 8;
+    string cash = 9;
+  }
+  google.protobuf.StringValue note = 10 [(validate.rules).string.min_len = 1];
+  int32 slot = 11 [(validate.rules).int32 = {gt: 10, lt: 5}];
+}
+`
+
+func newRequest(t *testing.T, body string) *dynamic.Message {
+	dir, err := ioutil.TempDir("", "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = os.Mkdir(filepath.Join(dir, "validate"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "validate", "validate.proto"), []byte(validateProto), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "orders.proto"), []byte(ordersProto), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fds, err := protoparse.Parser{ImportPaths: []string{dir}}.ParseFiles("orders.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := dynamic.NewMessage(fds[0].FindMessage("test.validate.CreateOrderRequest"))
+	if err = msg.UnmarshalJSON([]byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestMessage(t *testing.T) {
+	valid := `{"orderId": "0b7e3c3a-5f55-4f0a-9c4e-6f1d7f2d9a10", "email": "a@b.io", "quantity": 5,
+		"status": "NEW", "tags": ["ab", "cd"], "item": {"sku": "AB-1"}, "card": "4242"}`
+	if vs := Message(newRequest(t, valid)); len(vs) != 0 {
+		t.Errorf("unexpected violations: %v", vs)
+	}
+
+	invalid := `{"orderId": "42", "email": "nope", "quantity": 101, "status": 7,
+		"tags": ["a", "cd", "cd"], "extra": [{"sku": "x"}]}`
+	var got []string
+	for _, v := range Message(newRequest(t, invalid)) {
+		got = append(got, v.Field+" "+v.Rule)
+	}
+	sort.Strings(got)
+	want := []string{
+		"email string.email",
+		"extra[0].sku string.pattern",
+		"item message.required",
+		"order_id string.uuid",
+		"payment oneof.required",
+		"quantity int32.gt_lte",
+		"status enum.defined_only",
+		"tags repeated.unique",
+		"tags[0] string.min_len",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("violations:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWrappersAndRanges(t *testing.T) {
+	const base = `"orderId": "0b7e3c3a-5f55-4f0a-9c4e-6f1d7f2d9a10", "email": "a@b.io", "quantity": 5,
+		"status": "NEW", "tags": ["ab", "cd"], "item": {"sku": "AB-1"}, "card": "4242"`
+	for _, tc := range []struct {
+		fields string
+		want   string
+	}{
+		{`"note": "hello"`, ""},
+		// Rules of unset wrappers are skipped.
+		{`"slot": 20`, ""},
+		{`"note": ""`, "note string.min_len"},
+		// gt above lt is an exclusive range.
+		{`"slot": 1`, ""},
+		{`"slot": 7`, "slot int32.gt_lt_exclusive"},
+		{`"slot": 10`, "slot int32.gt_lt_exclusive"},
+	} {
+		var got []string
+		for _, v := range Message(newRequest(t, "{"+base+", "+tc.fields+"}")) {
+			got = append(got, v.Field+" "+v.Rule)
+		}
+		if strings.Join(got, "\n") != tc.want {
+			t.Errorf("%s: got violations %v, want %q", tc.fields, got, tc.want)
+		}
+	}
+}